@@ -0,0 +1,224 @@
+package animecmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+const airingKey = "anime/airing"
+
+// minInterval is the fewest minutes a channel can set between checks for new
+// episodes.
+const minInterval = 5
+
+// defaultInterval is the number of minutes between checks for new episodes in
+// channels that haven't set their own.
+var defaultInterval float64 = 30
+
+// pollers stores the clients that have an airing poller running, so
+// reconnecting doesn't start another.
+var pollers = map[*ircutil.Client]bool{}
+
+// pollersMu guards pollers.
+var pollersMu sync.Mutex
+
+// Airing shows or changes how new episodes of airing shows are announced in a
+// channel. The setting can be "on", "off", or a number of minutes between
+// checks.
+// Function key: inami/animecmd.Airing
+func Airing(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Only channels get announcements.
+	if !ircutil.IsChannel(message.Target) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Announcements only work in channels")
+		return
+	}
+	keys := []string{"channel", message.Target, settingsKey, ""}
+	setting := strings.ToLower(configutil.GetArgs(command, message)["setting"])
+
+	// Send response with current settings, or update them.
+	var err error
+	text := ""
+	switch setting {
+	case "":
+		settings, _ := configutil.GetGroup(client, keys[:3])
+		text = fmt.Sprintf("Checking for new episodes every %s minutes",
+			formatMinutes(channelInterval(settings)))
+		if settings["announce"] == "off" {
+			text = "Not announcing new episodes here"
+		}
+	case "on", "off":
+		keys[3] = "announce"
+		err = configutil.SetValue(client, keys, setting)
+		text = "Announcing new episodes here"
+		if setting == "off" {
+			text = "No longer announcing new episodes here"
+		}
+	default:
+		minutes, perr := strconv.ParseFloat(setting, 64)
+		if perr != nil || minutes < minInterval {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("Use on, off, or a number of minutes (at least %d)",
+					minInterval))
+			return
+		}
+		keys[3] = "interval"
+		err = configutil.SetValue(client, keys, setting)
+		text = fmt.Sprintf("Checking for new episodes every %s minutes",
+			formatMinutes(minutes))
+	}
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error updating announcements, try again later")
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target, text)
+}
+
+// formatMinutes formats a number of minutes without trailing zeros.
+func formatMinutes(minutes float64) string {
+	return strconv.FormatFloat(minutes, 'f', -1, 64)
+}
+
+// channelInterval returns the number of minutes between checks for new
+// episodes from a channel's settings.
+func channelInterval(settings map[string]string) float64 {
+	minutes, err := strconv.ParseFloat(settings["interval"], 64)
+	if err != nil || minutes < minInterval {
+		return defaultInterval
+	}
+	return minutes
+}
+
+// startPoller starts checking for new episodes in a client's channels, unless
+// it's already running.
+func startPoller(client *ircutil.Client) {
+	pollersMu.Lock()
+	defer pollersMu.Unlock()
+	if pollers[client] {
+		return
+	}
+	pollers[client] = true
+	cmdutil.Go(client, "airing poller", func() { poll(client) })
+}
+
+// poll checks every minute for channels that are due to check for new
+// episodes, until the bot shuts down.
+func poll(client *ircutil.Client) {
+	ctx := cmdutil.Context()
+	checked := map[string]time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clockutil.After(time.Minute):
+		}
+		checkAiring(client, checked)
+	}
+}
+
+// checkAiring checks each due channel's aliased shows for new episodes, and
+// announces any it finds. Checked maps channels to when they were last
+// checked.
+func checkAiring(client *ircutil.Client, checked map[string]time.Time) {
+	now := clockutil.Now()
+	latest := map[string]int{}
+	for _, channel := range configutil.GetOwners(client, "channel") {
+		// Skip channels that opted out or aren't due.
+		settings, _ := configutil.GetGroup(client,
+			[]string{"channel", channel, settingsKey})
+		interval := time.Duration(channelInterval(settings) * float64(time.Minute))
+		if settings["announce"] == "off" || now.Sub(checked[channel]) < interval {
+			continue
+		}
+		checked[channel] = now
+
+		// Check each aliased show.
+		shows, _ := configutil.GetGroup(client,
+			[]string{"channel", channel, "anime/shows"})
+		for alias, value := range shows {
+			if _, ok := latest[value]; !ok {
+				latest[value] = latestAired(client, value, now)
+			}
+			announce(client, channel, alias, value, latest[value])
+		}
+	}
+}
+
+// latestAired returns the number of the latest aired episode of a show that's
+// still airing, or zero if it isn't airing or can't be fetched.
+func latestAired(client *ircutil.Client, value string, now time.Time) int {
+	// Get show data, and skip shows that aren't airing.
+	provider, id := parseShowID(value, "kitsu")
+	p := providers[provider]
+	show, err := p.Show(id)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		return 0
+	}
+	if !show.Airing {
+		return 0
+	}
+
+	// Get current episode data, and find the latest aired episode.
+	episodes, err := freshEpisodes(p, id)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		return 0
+	}
+	num := 0
+	for _, e := range episodes {
+		if e.Number > num && (e.Aired.IsZero() || !e.Aired.After(now)) {
+			num = e.Number
+		}
+	}
+	return num
+}
+
+// announce lets a channel know about a show's latest episode if it's newer
+// than the last one it was told about. The first episode seen for a show is
+// saved without an announcement.
+func announce(client *ircutil.Client, channel, alias, value string,
+	latest int) {
+	if latest < 1 {
+		return
+	}
+
+	// Compare with the last episode seen, and save the latest one.
+	keys := []string{"channel", channel, airingKey, value}
+	seenStr, _ := configutil.GetValue(client, keys)
+	seen, err := strconv.Atoi(seenStr)
+	if err == nil && latest <= seen {
+		return
+	}
+	configutil.SetValue(client, keys, strconv.Itoa(latest))
+	if err != nil {
+		return
+	}
+
+	// Send announcement with how far behind the channel is.
+	provider, id := parseShowID(value, "kitsu")
+	show, err := providers[provider].Show(id)
+	if err != nil || len(show.ID) < 1 {
+		return
+	}
+	text := fmt.Sprintf("Episode %d of %s is now out", latest, show.Title)
+	progressStr, _ := configutil.GetValue(client,
+		[]string{"channel", channel, progressKey, alias})
+	progress, _ := strconv.Atoi(progressStr)
+	if behind := latest - progress; behind > 0 {
+		text = fmt.Sprintf("%s — you're %d behind", text, behind)
+	}
+	msgutil.SendPrivmsg(client, channel, text)
+}
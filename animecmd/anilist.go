@@ -0,0 +1,178 @@
+package animecmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/webutil"
+)
+
+// anilist fetches data from the AniList GraphQL API.
+type anilist struct {
+	api *webutil.Client
+}
+
+// anilistEpisode matches streaming episode titles such as "Episode 3 - Title".
+var anilistEpisode = regexp.MustCompile(`^Episode (\d+) - (.+)$`)
+
+// anilistMedia stores a show returned by the AniList API.
+type anilistMedia struct {
+	ID    int `json:"id"`
+	Title struct {
+		Preferred string `json:"userPreferred"`
+	} `json:"title"`
+	URL               string `json:"siteUrl"`
+	Status            string `json:"status"`
+	Episodes          int    `json:"episodes"`
+	Duration          int    `json:"duration"`
+	NextAiringEpisode *struct {
+		Episode int `json:"episode"`
+	} `json:"nextAiringEpisode"`
+	StreamingEpisodes []struct {
+		Title string `json:"title"`
+	} `json:"streamingEpisodes"`
+}
+
+// show converts an AniList show to show data.
+func (m anilistMedia) show() Show {
+	return Show{
+		ID:       strconv.Itoa(m.ID),
+		Title:    m.Title.Preferred,
+		URL:      m.URL,
+		Airing:   m.Status == "RELEASING",
+		Length:   time.Duration(m.Duration) * time.Minute,
+		Episodes: m.Episodes,
+	}
+}
+
+// query sends a GraphQL query to the AniList API and parses the response's
+// data into v. Data that wasn't found is left empty.
+func (a anilist) query(query string, variables map[string]interface{},
+	v interface{}) error {
+	// Send query to AniList API.
+	data := struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+	err := a.api.Post("", map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	}, &data)
+	if webutil.IsStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Return the first error the API reported if there's no data.
+	if len(data.Errors) > 0 {
+		return errors.New("anilist: " + data.Errors[0].Message)
+	}
+	if len(data.Data) < 1 || string(data.Data) == "null" {
+		return nil
+	}
+	if err = json.Unmarshal(data.Data, v); err != nil {
+		return &webutil.DecodeError{URL: a.api.BaseURL, Err: err}
+	}
+	return nil
+}
+
+// media queries the AniList API for a show by its id. The show's id is zero if
+// it wasn't found.
+func (a anilist) media(id string) (anilistMedia, error) {
+	// AniList ids are always numbers, so anything else can't be found.
+	num, err := strconv.Atoi(id)
+	if err != nil {
+		return anilistMedia{}, nil
+	}
+
+	// Query show data.
+	data := struct {
+		Media *anilistMedia `json:"Media"`
+	}{}
+	err = a.query(`query ($id: Int) {
+		Media(id: $id, type: ANIME) {
+			id title { userPreferred } siteUrl status episodes duration
+			nextAiringEpisode { episode } streamingEpisodes { title }
+		}
+	}`, map[string]interface{}{"id": num}, &data)
+	if err != nil || data.Media == nil {
+		return anilistMedia{}, err
+	}
+	return *data.Media, nil
+}
+
+// Search queries the AniList API and returns search results as a slice.
+func (a anilist) Search(query string) ([]Show, error) {
+	data := struct {
+		Page struct {
+			Media []anilistMedia `json:"media"`
+		} `json:"Page"`
+	}{}
+	err := a.query(`query ($search: String) {
+		Page(perPage: 5) {
+			media(search: $search, type: ANIME) {
+				id title { userPreferred } siteUrl status episodes duration
+			}
+		}
+	}`, map[string]interface{}{"search": query}, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return slice of shows.
+	var shows []Show
+	for _, m := range data.Page.Media {
+		shows = append(shows, m.show())
+	}
+	return shows, nil
+}
+
+// Show queries the AniList API and returns show data.
+func (a anilist) Show(id string) (Show, error) {
+	m, err := a.media(id)
+	if err != nil || m.ID == 0 {
+		return Show{}, err
+	}
+	return m.show(), nil
+}
+
+// Episodes queries the AniList API and returns episode data. AniList doesn't
+// list episodes directly, so they're numbered up to the episode count (or the
+// latest aired episode for shows still airing), and titled using streaming
+// episode titles where they're available.
+func (a anilist) Episodes(id string) ([]Episode, error) {
+	m, err := a.media(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect titles from streaming episodes.
+	titles := map[int]string{}
+	for _, s := range m.StreamingEpisodes {
+		match := anilistEpisode.FindStringSubmatch(s.Title)
+		if match == nil {
+			continue
+		}
+		num, _ := strconv.Atoi(match[1])
+		titles[num] = match[2]
+	}
+
+	// Determine episode count and return slice of episodes.
+	count := m.Episodes
+	if m.NextAiringEpisode != nil {
+		count = m.NextAiringEpisode.Episode - 1
+	}
+	var episodes []Episode
+	for i := 1; i <= count; i++ {
+		episodes = append(episodes, Episode{Number: i, Title: titles[i]})
+	}
+	return episodes, nil
+}
@@ -0,0 +1,107 @@
+package animecmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jasonpuglisi/inami-irc-bot/cacheutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+)
+
+// cached wraps a provider so its responses are kept in the shared cache.
+type cached struct {
+	name     string
+	provider AnimeProvider
+	ttl      configutil.AnimeTTL
+}
+
+// key returns the cache key for a type of response from the provider.
+func (c cached) key(kind, id string) string {
+	return fmt.Sprintf("anime/%s/%s/%s", c.name, kind, id)
+}
+
+// Search returns cached search results, or fetches and caches them.
+func (c cached) Search(query string) ([]Show, error) {
+	key := c.key("search", strings.ToLower(query))
+	var shows []Show
+	if cacheutil.GetJSON(cacheutil.Default, key, &shows) {
+		return shows, nil
+	}
+	shows, err := c.provider.Search(query)
+	if err != nil {
+		return nil, err
+	}
+	cacheutil.SetJSON(cacheutil.Default, key, shows,
+		cacheutil.Seconds(c.ttl.Search))
+	return shows, nil
+}
+
+// Show returns cached show data, or fetches and caches it. Shows that weren't
+// found aren't cached, so they can be found once they're added.
+func (c cached) Show(id string) (Show, error) {
+	key := c.key("show", id)
+	var show Show
+	if cacheutil.GetJSON(cacheutil.Default, key, &show) {
+		return show, nil
+	}
+	show, err := c.provider.Show(id)
+	if err != nil || len(show.ID) < 1 {
+		return show, err
+	}
+	cacheutil.SetJSON(cacheutil.Default, key, show,
+		cacheutil.Seconds(c.ttl.Show))
+	return show, nil
+}
+
+// Episodes returns a cached episode list, or fetches and caches it.
+func (c cached) Episodes(id string) ([]Episode, error) {
+	key := c.key("episodes", id)
+	var episodes []Episode
+	if cacheutil.GetJSON(cacheutil.Default, key, &episodes) {
+		return episodes, nil
+	}
+	episodes, err := c.provider.Episodes(id)
+	if err != nil {
+		return nil, err
+	}
+	cacheutil.SetJSON(cacheutil.Default, key, episodes,
+		cacheutil.Seconds(c.ttl.Episodes))
+	return episodes, nil
+}
+
+// Slug returns cached show data for a show's URL name, or fetches and caches
+// it if the provider supports it.
+func (c cached) Slug(slug string) (Show, error) {
+	p, ok := c.provider.(slugProvider)
+	if !ok {
+		return Show{}, nil
+	}
+	key := c.key("slug", slug)
+	var show Show
+	if cacheutil.GetJSON(cacheutil.Default, key, &show) {
+		return show, nil
+	}
+	show, err := p.Slug(slug)
+	if err != nil || len(show.ID) < 1 {
+		return show, err
+	}
+	cacheutil.SetJSON(cacheutil.Default, key, show,
+		cacheutil.Seconds(c.ttl.Show))
+	return show, nil
+}
+
+// freshEpisodes fetches a show's episode list from a provider even if it's
+// cached, and updates the cache with it.
+func freshEpisodes(provider AnimeProvider, id string) ([]Episode, error) {
+	c, ok := provider.(cached)
+	if !ok {
+		return provider.Episodes(id)
+	}
+	episodes, err := c.provider.Episodes(id)
+	if err != nil {
+		return nil, err
+	}
+	cacheutil.SetJSON(cacheutil.Default, c.key("episodes", id), episodes,
+		cacheutil.Seconds(c.ttl.Episodes))
+	return episodes, nil
+}
@@ -1,299 +1,629 @@
 package animecmd
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
 	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/modutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
 	"github.com/jasonpuglisi/ircutil"
 )
 
 const progressKey = "anime/progress"
 
-// Init adds animecmd's functions to the command map.
-func Init(cmdMap ircutil.CmdMap) {
+func init() {
+	modutil.Register("animecmd", modutil.Module{
+		Init: func(cmdMap ircutil.CmdMap, config *configutil.Config) {
+			Init(cmdMap, config.Anime)
+		},
+		Ready: startPoller,
+	})
+}
+
+// Init adds animecmd's functions to the command map, and sets up providers
+// with the given settings.
+func Init(cmdMap ircutil.CmdMap, anime configutil.Anime) {
+	setProviders(anime)
+	if anime.Interval >= minInterval {
+		defaultInterval = anime.Interval
+	}
+	if anime.ReadyTimeout > 0 {
+		readyTimeout = time.Duration(anime.ReadyTimeout * float64(time.Second))
+	}
+	if anime.Intermission >= 0 && anime.Intermission <= maxIntermission {
+		defaultIntermission = int(anime.Intermission)
+	}
+	if anime.MaxMarathon > 0 {
+		maxMarathon = anime.MaxMarathon
+	}
 	ircutil.AddCommand(cmdMap, "inami/animecmd.Countdown", Countdown)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Sync", Sync)
 	ircutil.AddCommand(cmdMap, "inami/animecmd.Alias", Alias)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Pick", Pick)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Provider", Provider)
 	ircutil.AddCommand(cmdMap, "inami/animecmd.Search", Search)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Airing", Airing)
 	ircutil.AddCommand(cmdMap, "inami/animecmd.Watch", Watch)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Ready", Ready)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Unready", Unready)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Start", Start)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Pause", Pause)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Resume", Resume)
 	ircutil.AddCommand(cmdMap, "inami/animecmd.Progress", Progress)
 	ircutil.AddCommand(cmdMap, "inami/animecmd.Next", Next)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.MyProgress", MyProgress)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.Behind", Behind)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.History", History)
+	ircutil.AddCommand(cmdMap, "inami/animecmd.LastWatched", LastWatched)
 }
 
-// Countdown starts a countdown to coordinate group watching.
-// Function key: inami.animeutil/Countdown
+// Countdown starts a countdown to coordinate group watching, or stops the
+// active one. Function key: inami.animeutil/Countdown
 func Countdown(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	// Send response for countdown start.
-	ircutil.SendResponse(client, message.Source, message.Target,
-		"Starting countdown, press play when I say \"Start!\"")
-
-	// Send response with seconds remaining, or "Start!" at 0, and decrement
-	// seconds remaining.
-	ticker := time.NewTicker(time.Second)
-	i := 6
-	for range ticker.C {
-		s := strconv.Itoa(i)
-		if i == 0 {
-			s = "Start!"
+	// Stop the active countdown if requested.
+	target := sessionTarget(message)
+	if configutil.GetArgs(command, message)["action"] == "stop" {
+		stopSession(client, message, target)
+		return
+	}
+
+	// Start a session so countdowns don't overlap, then count down.
+	ctx, end, ok := cmdutil.BeginSession(client, target)
+	if !ok {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"A countdown is already running here, stop it first")
+		return
+	}
+	defer end()
+	countdown(ctx, client, message)
+}
+
+// stopSession cancels the active countdown or watching session for a target,
+// and sends a response if there isn't one.
+func stopSession(client *ircutil.Client, message *ircutil.Message,
+	target string) {
+	if !cmdutil.CancelSession(client, target) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Nothing is running here")
+	}
+}
+
+// sessionTarget returns the channel a message was sent to, or the nickname of
+// its sender if it was sent directly.
+func sessionTarget(message *ircutil.Message) string {
+	if ircutil.IsChannel(message.Target) {
+		return message.Target
+	}
+	return ircutil.GetNick(message.Source)
+}
+
+// commandTrigger returns the full trigger of the first command using a
+// function, so responses show the trigger users actually type. It returns the
+// fallback if no command uses the function.
+func commandTrigger(client *ircutil.Client, function,
+	fallback string) string {
+	for i := range client.Commands {
+		c := &client.Commands[i]
+		if c.Function == function && len(c.Triggers) > 0 {
+			return cmdutil.FormatTrigger(client, c, c.Triggers[0])
+		}
+	}
+	return fallback
+}
+
+// Alias assigns a show to a custom alias. The show can be given as an id
+// (optionally prefixed with a provider name, such as "anilist:21"), a show
+// URL, a Kitsu slug, or a title to search for. Searches with more than one
+// result let the user pick one, and a bare number is searched for as a title
+// too. Arguments in the old order, an id or URL followed by an alias, are
+// swapped.
+func Alias(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	args := configutil.GetArgs(command, message)
+	alias, input := args["alias"], args["show"]
+	provider := getProvider(client, message)
+
+	// Swap arguments given in the old order and let the user know it changed.
+	if isShowRef(alias) && !strings.Contains(input, " ") && !isShowRef(input) {
+		alias, input = input, alias
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("The alias comes first now, like %s %s %s",
+				commandTrigger(client, "inami/animecmd.Alias", "alias"), alias,
+				input))
+	}
+
+	// Assign show directly if it's identified by a single word, unless it's a
+	// bare number that could also be a title, like "86".
+	var found []Show
+	if !strings.Contains(input, " ") {
+		p, show, err := identifyShow(provider, input)
+		if err != nil {
+			sendFetchError(client, message, "show", err)
+			return
 		}
-		if i < 6 {
-			ircutil.SendResponse(client, message.Source, message.Target,
-				s)
+		_, numErr := strconv.Atoi(input)
+		if len(show.ID) > 0 && numErr != nil {
+			saveAlias(client, message, alias, p, show)
+			return
 		}
-		if i == 0 {
-			break
+		if len(show.ID) > 0 {
+			found = append(found, show)
 		}
-		i--
 	}
-	ticker.Stop()
+
+	// Search provider for shows matching the title, listing the show with a
+	// matching id first.
+	shows, err := providers[provider].Search(input)
+	if err != nil && len(found) < 1 {
+		sendFetchError(client, message, "shows", err)
+		return
+	}
+	if len(found) > 0 {
+		for _, s := range shows {
+			if s.ID != found[0].ID {
+				found = append(found, s)
+			}
+		}
+		shows = found
+	}
+
+	// Send response if no shows are found, assign the show if only one is, or
+	// let the user pick one.
+	switch len(shows) {
+	case 0:
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"No shows found")
+	case 1:
+		saveAlias(client, message, alias, provider, shows[0])
+	default:
+		offerPicks(client, message, alias, provider, shows)
+	}
 }
 
-// Alias saves a show identifier as a custom alias.
-func Alias(client *ircutil.Client, command *ircutil.Command,
+// isShowRef checks whether a word refers to a show by id or URL rather than
+// by name.
+func isShowRef(word string) bool {
+	if _, _, _, ok := parseShowURL(word); ok {
+		return true
+	}
+	if p, _ := parseShowID(word, ""); len(p) > 0 {
+		return true
+	}
+	_, err := strconv.Atoi(word)
+	return err == nil
+}
+
+// Provider sets the anime database used in the command's scope, or sends the
+// current one if no name is given.
+// Function key: inami/animecmd.Provider
+func Provider(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	// Set alias and value, and update scope/owner to match command scope.
-	id, alias := message.Args[0], message.Args[1]
-	keys := []string{"", "", "anime/shows", alias}
-	configutil.UpdateScope(keys, message.Source, message.Target)
+	// Send response with current and available providers if none was given.
+	name := strings.ToLower(configutil.GetArgs(command, message)["name"])
+	names := strings.Join(providerNames(), ", ")
+	if len(name) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Using %s, available providers are %s",
+				getProvider(client, message), names))
+		return
+	}
 
-	// Set alias, intialize episode progress, and send response with
-	// confirmation.
-	configutil.SetValue(client, keys, id)
-	keys[2] = progressKey
-	configutil.SetValue(client, keys, "0")
-	ircutil.SendResponse(client, message.Source, message.Target,
-		fmt.Sprintf("Aliased %s to %s", id, alias))
+	// Make sure provider exists.
+	if _, ok := providers[name]; !ok {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Unknown provider, available providers are %s", names))
+		return
+	}
+
+	// Set provider in persistent data and send response with confirmation.
+	keys := []string{"", "", settingsKey, "provider"}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+	err := configutil.SetValue(client, keys, name)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error setting provider, try again later")
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Now using %s for new aliases and searches", name))
 }
 
 // Search searches an anime database and returns relevant search results.
 // Function key: inami/animecmd.Search
 func Search(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	// Search Kitsu for shows matching query.
-	shows, err := search(strings.Join(message.Args, " "))
+	// Search provider for shows matching query.
+	provider := getProvider(client, message)
+	shows, err := providers[provider].Search(
+		configutil.GetArgs(command, message)["query"])
 	if err != nil {
-		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Error fetching shows, try again later")
+		sendFetchError(client, message, "shows", err)
 		return
 	}
 
 	// Send response if no shows are found.
 	if len(shows) < 1 {
-		ircutil.SendResponse(client, message.Source, message.Target,
+		msgutil.SendResponse(client, message.Source, message.Target,
 			"No shows found")
 		return
 	}
 
-	// Send response with found shows and their URLs.
-	ircutil.SendResponse(client, message.Source, message.Target,
-		"Shows found:")
+	// Send response with found shows and their ids.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Shows found on %s:", provider))
 	for _, s := range shows {
-		ircutil.SendResponse(client, message.Source, message.Target,
-			fmt.Sprintf("- [%s] %s", s.ID, s.Attributes.Title))
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("- [%s] %s", s.ID, s.Title))
 	}
 }
 
-// Watch gets data of a show and starts a group watching session.
+// Watch gets data of a show and opens a watch party for its next episode, or
+// a marathon of its next few episodes, or cancels the active one. The first
+// countdown starts once everyone who joined is ready, and each following one
+// starts after the previous episode and an intermission. The party can be
+// paused and resumed until the last episode ends. Only countdowns and the
+// wait for everyone to be ready hold the target's session.
 // Function key: inami/animecmd.Watch
 func Watch(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	// Set alias and update scope/owner to match command scope.
-	alias := message.Args[0]
-	keys := []string{"", "", "anime/shows", alias}
-	configutil.UpdateScope(keys, message.Source, message.Target)
-
-	// Get show id from alias in persistent data.
-	id, err := configutil.GetValue(client, keys)
-	if err != nil {
-		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Error getting alias, try again later")
-		return
-	}
-	if len(id) < 1 {
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Alias not found, make sure you've assigned a show to it")
+	// Cancel the active session or playing party if requested.
+	args := configutil.GetArgs(command, message)
+	alias := args["alias"]
+	if alias == "cancel" {
+		if cmdutil.CancelSession(client, sessionTarget(message)) {
+			return
+		}
+		text := "Nothing is running here"
+		if cancelParty(client, message) {
+			text = "Watch party cancelled"
+		}
+		msgutil.SendResponse(client, message.Source, message.Target, text)
 		return
 	}
 
-	// Get episode number from alias in persistent data.
-	keys[2] = progressKey
-	numStr, _ := configutil.GetValue(client, keys)
-	num, _ := strconv.Atoi(numStr)
-	num++
+	// Parse how many episodes to watch from arguments.
+	count := 1
+	if len(args["count"]) > 0 {
+		n, err := strconv.Atoi(args["count"])
+		if err != nil || n < 1 || n > maxMarathon {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("Count must be from 1 to %d episodes", maxMarathon))
+			return
+		}
+		count = n
+	}
 
-	// Get show data from Kitsu.
-	show, err := show(id)
-	if err != nil {
-		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Error fetching show, try again later")
+	// Get show and the episodes to watch.
+	show, episodes, ok := nextEpisodes(client, message, alias, count)
+	if !ok {
 		return
 	}
+	if len(episodes) < count {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Only %d episode%s available, watching %s",
+				len(episodes), plural(len(episodes)), "those instead"))
+	}
 
-	// Send response if show not found.
-	if len(show.Attributes.Slug) < 1 {
-		ircutil.SendResponse(client, message.Source, message.Target,
-			fmt.Sprintf("Show not found, make sure your alias is using %s",
-				"the name after /anime/ in the show's URL"))
+	// Start a session so countdowns don't overlap, and open a party in it.
+	ctx, end, ok := cmdutil.BeginSession(client, sessionTarget(message))
+	if !ok {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"A countdown is already running here, stop it first")
 		return
 	}
+	p, closeParty := openParty(client, message)
+
+	// Wait for everyone to be ready, then start the first countdown.
+	first, last := episodes[0], episodes[len(episodes)-1]
+	text := fmt.Sprintf("Watch party for %s Episode %d%s is starting",
+		show.Title, first.Number, first.quotedTitle())
+	if len(episodes) > 1 {
+		text = fmt.Sprintf("Marathon of %s Episodes %d to %d is starting",
+			show.Title, first.Number, last.Number)
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("%s, type %s when you're ready", text,
+			commandTrigger(client, "inami/animecmd.Ready", "ready")))
+	started := waitReady(ctx, client, message, p)
+	if started {
+		p.setPhase(phaseCounting)
+		started = countdown(ctx, client, message)
+	}
 
-	// Get episode data from Kitsu.
-	episodes, err := episodes(id)
-	if err != nil {
-		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Error fetching episodes, try again later")
+	// Release the session once the countdown ends so other countdowns can
+	// run, and keep only the party open for pauses until the last episode
+	// ends.
+	end()
+	if !started {
+		closeParty()
 		return
 	}
+	cmdutil.Go(client, "watch party", func() {
+		defer closeParty()
+		marathon(client, message, p, alias, show, episodes)
+	})
+}
 
-	// Find specific episode.
-	var episode *result
-	for i := range episodes {
-		e := &episodes[i]
-		if e.Attributes.Number == num {
-			episode = e
-			break
+// marathon plays each episode of a party once its first countdown is done,
+// with an intermission and another countdown before each following one.
+func marathon(client *ircutil.Client, message *ircutil.Message, p *party,
+	alias string, show Show, episodes []Episode) {
+	length := show.Length
+	if length <= 0 {
+		length = episodeLength
+	}
+	for i, e := range episodes {
+		if i > 0 && (!intermission(p.ctx, client, message, p, show, e) ||
+			!partyCountdown(client, message, p)) {
+			return
+		}
+		if !watchEpisode(client, message, p, alias, show, e, length) {
+			return
 		}
 	}
+	if len(episodes) > 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Marathon finished, you watched %d episodes of %s",
+				len(episodes), show.Title))
+	}
+}
 
-	// Clear episode title if it doesn't exist.
-	episodeTitle := ""
-	if episode != nil && len(episode.Attributes.Title) > 0 &&
-		episode.Attributes.Title != fmt.Sprintf("Episode %d", num) {
-		episodeTitle = fmt.Sprintf(" \"%s\"", episode.Attributes.Title)
+// partyCountdown counts a party down to its next episode in a session, so it
+// doesn't overlap other countdowns, waiting for any that's running to finish.
+// It returns false if the countdown or the party was cancelled.
+func partyCountdown(client *ircutil.Client, message *ircutil.Message,
+	p *party) bool {
+	// Start a session once nothing else is counting down.
+	ctx, end, ok := cmdutil.BeginSession(client, sessionTarget(message))
+	for !ok {
+		select {
+		case <-p.ctx.Done():
+			return false
+		case <-clockutil.After(time.Second):
+		}
+		ctx, end, ok = cmdutil.BeginSession(client, sessionTarget(message))
 	}
+	defer end()
+
+	// Stop the countdown if the party is cancelled too.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(p.ctx, cancel)()
+	p.setPhase(phaseCounting)
+	return countdown(ctx, client, message)
+}
 
-	// Start countdown and sleep before sending episode title.
-	Countdown(client, command, message)
-	time.Sleep(time.Second * 5)
+// watchEpisode updates progress and history once a party's episode starts,
+// and keeps the party open for pauses until it ends. It returns false if the
+// party was cancelled first.
+func watchEpisode(client *ircutil.Client, message *ircutil.Message,
+	p *party, alias string, show Show, episode Episode,
+	length time.Duration) bool {
+	// Wait before sending episode title.
+	select {
+	case <-p.ctx.Done():
+		return false
+	case <-clockutil.After(time.Second * 5):
+	}
 
-	// Send response with episode information, and increment episode number.
-	ircutil.SendResponse(client, message.Source, message.Target,
-		fmt.Sprintf("You're watching %s Episode %d%s", show.Attributes.Title, num,
-			episodeTitle))
-	configutil.SetValue(client, keys, strconv.Itoa(num))
+	// Send response with episode information, increment episode number for
+	// the group and its participants, and record it in history.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("You're watching %s Episode %d%s", show.Title,
+			episode.Number, episode.quotedTitle()))
+	keys := []string{"", "", progressKey, alias}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+	configutil.SetValue(client, keys, strconv.Itoa(episode.Number))
+	nicks := p.participants()
+	if ircutil.IsChannel(message.Target) {
+		advanceMembers(client, message.Target, alias, nicks, episode.Number)
+	}
+	addHistory(client, message, alias, historyEntry{
+		Show:         show.Title,
+		Episode:      episode.Number,
+		Title:        episode.Title,
+		Participants: nicks,
+	})
+
+	// Keep the party open for pauses until the episode ends.
+	play(p.ctx, client, message, p, length)
+	return p.ctx.Err() == nil
 }
 
 // Progress updates a show's episode number.
 func Progress(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
 	// Parse episode number from arguments.
-	num, err := strconv.Atoi(message.Args[1])
+	args := configutil.GetArgs(command, message)
+	num, err := strconv.Atoi(args["episode"])
 	if err != nil || num < 0 {
-		ircutil.SendResponse(client, message.Source, message.Target,
+		msgutil.SendResponse(client, message.Source, message.Target,
 			"Invalid episode number")
-	}
-
-	// Set alias and update scope/owner to match command scope.
-	alias := message.Args[0]
-	keys := []string{"", "", "anime/shows", alias}
-	configutil.UpdateScope(keys, message.Source, message.Target)
-
-	// Get show name from alias in persistent data to make sure it exists.
-	id, err := configutil.GetValue(client, keys)
-	if err != nil {
-		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Error checking for alias, try again later")
 		return
 	}
-	if len(id) < 1 {
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Alias not found, make sure you've assigned a show to it")
+
+	// Get show id from alias in persistent data to make sure it exists.
+	alias := args["alias"]
+	provider, id, ok := getShowID(client, message, alias)
+	if !ok {
 		return
 	}
 
 	// Set episode number in persistent data and send response with confirmation.
-	keys[2] = progressKey
-	plural := "s"
-	if num == 1 {
-		plural = ""
-	}
+	keys := []string{"", "", progressKey, alias}
+	configutil.UpdateScope(keys, message.Source, message.Target)
 	configutil.SetValue(client, keys, strconv.Itoa(num))
-	ircutil.SendResponse(client, message.Source, message.Target,
+	recordProgress(client, message, alias, provider, id, num)
+	msgutil.SendResponse(client, message.Source, message.Target,
 		fmt.Sprintf("Updated show progress, you've watched %d episode%s", num,
-			plural))
+			plural(num)))
+}
+
+// recordProgress records a manual progress change in an alias's history,
+// titled from cached show data where it's available.
+func recordProgress(client *ircutil.Client, message *ircutil.Message, alias,
+	provider, id string, num int) {
+	entry := historyEntry{
+		Show:         alias,
+		Episode:      num,
+		Participants: []string{ircutil.GetNick(message.Source)},
+		Manual:       true,
+	}
+	if show, err := providers[provider].Show(id); err == nil &&
+		len(show.Title) > 0 {
+		entry.Show = show.Title
+	}
+	addHistory(client, message, alias, entry)
 }
 
 // Next returns information about the next episode for a show.
 func Next(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	// Set alias and update scope/owner to match command scope.
-	alias := message.Args[0]
+	// Get show and next episode.
+	alias := configutil.GetArgs(command, message)["alias"]
+	show, episodes, ok := nextEpisodes(client, message, alias, 1)
+	if !ok {
+		return
+	}
+
+	// Send response with next episode information.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Next up for %s is Episode %d%s", show.Title,
+			episodes[0].Number, episodes[0].quotedTitle()))
+}
+
+// getShowID gets the provider name and show id an alias refers to in a
+// message's scope, and sends a response if the alias doesn't exist.
+func getShowID(client *ircutil.Client, message *ircutil.Message,
+	alias string) (string, string, bool) {
+	// Update scope/owner to match command scope.
 	keys := []string{"", "", "anime/shows", alias}
 	configutil.UpdateScope(keys, message.Source, message.Target)
 
-	// Get show id from alias in persistent data to make sure it exists.
-	id, err := configutil.GetValue(client, keys)
+	// Get show id from alias in persistent data.
+	value, err := configutil.GetValue(client, keys)
 	if err != nil {
 		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Error checking for alias, try again later")
-		return
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error getting alias, try again later")
+		return "", "", false
 	}
-	if len(id) < 1 {
-		ircutil.SendResponse(client, message.Source, message.Target,
+	if len(value) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
 			"Alias not found, make sure you've assigned a show to it")
-		return
+		return "", "", false
+	}
+
+	// Aliases saved before providers existed are Kitsu ids.
+	provider, id := parseShowID(value, "kitsu")
+	return provider, id, true
+}
+
+// nextEpisodes gets the show an alias refers to, along with up to count
+// episodes after its saved progress. Episodes past the show's episode count
+// are left out, or past the latest episode the provider lists if the count
+// isn't known or the show is still airing. If neither is known, only the next
+// episode is included. Episode titles are empty if they don't have one. It
+// sends a response if anything can't be fetched or nothing is left to watch.
+func nextEpisodes(client *ircutil.Client, message *ircutil.Message,
+	alias string, count int) (Show, []Episode, bool) {
+	// Get provider and show id from alias.
+	provider, id, ok := getShowID(client, message, alias)
+	if !ok {
+		return Show{}, nil, false
 	}
+	p := providers[provider]
 
 	// Get episode number from alias in persistent data.
-	keys[2] = progressKey
-	strNum, _ := configutil.GetValue(client, keys)
-	num, _ := strconv.Atoi(strNum)
+	keys := []string{"", "", progressKey, alias}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+	numStr, _ := configutil.GetValue(client, keys)
+	num, _ := strconv.Atoi(numStr)
 	num++
 
-	// Get show data from Kitsu.
-	show, err := show(id)
+	// Get show data from provider.
+	show, err := p.Show(id)
 	if err != nil {
-		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Error fetching show, try again later")
-		return
+		sendFetchError(client, message, "show", err)
+		return Show{}, nil, false
 	}
 
 	// Send response if show not found.
-	if len(show.Attributes.Slug) < 1 {
-		ircutil.SendResponse(client, message.Source, message.Target,
-			fmt.Sprintf("Show not found, make sure your alias is using %s",
-				"the name after /anime/ in the show's URL"))
-		return
+	if len(show.ID) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Show not found, try assigning it to your alias again")
+		return Show{}, nil, false
 	}
 
-	// Get episode data from Kitsu.
-	episodes, err := episodes(id)
+	// Get episode data from provider.
+	episodes, err := p.Episodes(id)
 	if err != nil {
-		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
-			"Error fetching episodes, try again later")
-		return
+		sendFetchError(client, message, "episodes", err)
+		return Show{}, nil, false
 	}
 
-	// Find specific episode.
-	var episode *result
-	for i := range episodes {
-		e := &episodes[i]
-		if e.Attributes.Number == num {
-			episode = e
-			break
+	// Work out the last episode that can be watched.
+	known := map[int]Episode{}
+	latest := 0
+	for _, e := range episodes {
+		known[e.Number] = e
+		if e.Number > latest {
+			latest = e.Number
 		}
 	}
+	last := show.Episodes
+	if (show.Airing || last < 1) && latest > 0 {
+		last = latest
+	}
+	if last < 1 {
+		count = 1
+	} else if num > last {
+		text := fmt.Sprintf("Nothing left to watch for %s, it only has %d "+
+			"episode%s", alias, last, plural(last))
+		if show.Airing {
+			text = fmt.Sprintf("Nothing new to watch for %s yet, only %d "+
+				"episode%s out so far", alias, last, plural(last))
+		}
+		msgutil.SendResponse(client, message.Source, message.Target, text)
+		return Show{}, nil, false
+	} else if num+count-1 > last {
+		count = last - num + 1
+	}
 
-	// Clear episode title if it doesn't exist.
-	episodeTitle := ""
-	if episode != nil && len(episode.Attributes.Title) > 0 &&
-		episode.Attributes.Title != fmt.Sprintf("Episode %d", num) {
-		episodeTitle = fmt.Sprintf(" \"%s\"", episode.Attributes.Title)
+	// Find specific episodes, and clear titles of those that don't have one.
+	var next []Episode
+	for n := num; n < num+count; n++ {
+		e := known[n]
+		e.Number = n
+		if e.Title == fmt.Sprintf("Episode %d", n) {
+			e.Title = ""
+		}
+		next = append(next, e)
 	}
+	return show, next, true
+}
 
-	// Send response with next episode information.
-	ircutil.SendResponse(client, message.Source, message.Target,
-		fmt.Sprintf("Next up for %s is Episode %d%s", show.Attributes.Title, num,
-			episodeTitle))
+// plural returns "s" unless a count is exactly one.
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// quotedTitle returns an episode's title in quotes with a leading space, or
+// nothing if it doesn't have a title.
+func (e Episode) quotedTitle() string {
+	if len(e.Title) < 1 {
+		return ""
+	}
+	return fmt.Sprintf(" \"%s\"", e.Title)
 }
@@ -0,0 +1,229 @@
+package animecmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// countdownSettings stores how countdowns run in a channel.
+type countdownSettings struct {
+	// Either "ticks" to count down one message per second, or "clock" to
+	// announce a start time.
+	mode string
+	// Seconds counted down, one message each, before "Start!".
+	length int
+	// Seconds between announcing a start time and the start.
+	lead int
+	// Minutes between episodes of a marathon.
+	intermission int
+}
+
+// Limits for countdown settings, in seconds.
+const (
+	maxLength = 30
+	minLead   = 10
+	maxLead   = 300
+)
+
+// maxIntermission is the longest intermission a channel can set, in minutes.
+const maxIntermission = 30
+
+// defaultIntermission is the intermission used when a channel hasn't set one,
+// in minutes.
+var defaultIntermission = 5
+
+// getCountdownSettings returns the countdown settings for a message's scope,
+// with defaults for any that aren't set.
+func getCountdownSettings(client *ircutil.Client,
+	message *ircutil.Message) countdownSettings {
+	keys := []string{"", "", settingsKey}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+	values, _ := configutil.GetGroup(client, keys)
+	settings := countdownSettings{mode: "ticks", length: 5, lead: 30,
+		intermission: defaultIntermission}
+	if values["countdownMode"] == "clock" {
+		settings.mode = "clock"
+	}
+	if n, err := strconv.Atoi(values["countdownLength"]); err == nil {
+		settings.length = n
+	}
+	if n, err := strconv.Atoi(values["countdownLead"]); err == nil {
+		settings.lead = n
+	}
+	if n, err := strconv.Atoi(values["intermission"]); err == nil {
+		settings.intermission = n
+	}
+	return settings
+}
+
+// Sync shows or changes how countdowns run in the command's scope. The mode
+// is "ticks" to count down one message per second, or "clock" to announce a
+// start time so lag doesn't matter. The length is how many seconds are ticked
+// down before starting, the lead is how far ahead a start time is, and the
+// intermission is how many minutes pass between episodes of a marathon.
+// Function key: inami/animecmd.Sync
+func Sync(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	args := configutil.GetArgs(command, message)
+	setting, value := strings.ToLower(args["setting"]), args["value"]
+
+	// Send response with current settings if none are being changed.
+	if len(setting) < 1 || len(value) < 1 {
+		s := getCountdownSettings(client, message)
+		text := fmt.Sprintf("Counting down %d seconds", s.length)
+		if s.mode == "clock" {
+			text = fmt.Sprintf("Announcing a start time %d seconds ahead, %s %d",
+				s.lead, "then counting down", s.length)
+		}
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("%s, with %d minute%s between marathon episodes", text,
+				s.intermission, plural(s.intermission)))
+		return
+	}
+
+	// Validate the new value.
+	var key, text string
+	n, err := strconv.Atoi(value)
+	switch setting {
+	case "mode":
+		value = strings.ToLower(value)
+		if value != "ticks" && value != "clock" {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Mode must be ticks or clock")
+			return
+		}
+		key, text = "countdownMode", fmt.Sprintf("Countdowns now use %s", value)
+	case "length":
+		if err != nil || n < 0 || n > maxLength {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("Length must be from 0 to %d seconds", maxLength))
+			return
+		}
+		key, text = "countdownLength",
+			fmt.Sprintf("Countdowns now count down %d seconds", n)
+	case "lead":
+		if err != nil || n < minLead || n > maxLead {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("Lead must be from %d to %d seconds", minLead, maxLead))
+			return
+		}
+		key, text = "countdownLead",
+			fmt.Sprintf("Start times are now %d seconds ahead", n)
+	case "intermission":
+		if err != nil || n < 0 || n > maxIntermission {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("Intermission must be from 0 to %d minutes",
+					maxIntermission))
+			return
+		}
+		key, text = "intermission",
+			fmt.Sprintf("Marathons now take %d minute%s between episodes", n,
+				plural(n))
+	default:
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Setting must be mode, length, lead, or intermission")
+		return
+	}
+
+	// Set value in persistent data and send response with confirmation.
+	keys := []string{"", "", settingsKey, key}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+	err = configutil.SetValue(client, keys, value)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error updating countdowns, try again later")
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target, text)
+}
+
+// countdown sends a countdown to coordinate group watching, using the
+// settings for the message's scope. It returns false if the context was
+// cancelled before the countdown finished.
+func countdown(ctx context.Context, client *ircutil.Client,
+	message *ircutil.Message) bool {
+	settings := getCountdownSettings(client, message)
+	if settings.mode == "clock" {
+		return clockCountdown(ctx, client, message, settings)
+	}
+	return tickCountdown(ctx, client, message, settings.length)
+}
+
+// tickCountdown sends one message per second, counting down from a length
+// to "Start!".
+func tickCountdown(ctx context.Context, client *ircutil.Client,
+	message *ircutil.Message, length int) bool {
+	// Send response for countdown start.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		"Starting countdown, press play when I say \"Start!\"")
+
+	// Send response with seconds remaining, or "Start!" at 0, and decrement
+	// seconds remaining.
+	for i := length + 1; i >= 0; i-- {
+		select {
+		case <-ctx.Done():
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Countdown stopped")
+			return false
+		case <-clockutil.After(time.Second):
+		}
+		s := strconv.Itoa(i)
+		if i == 0 {
+			s = "Start!"
+		}
+		if i <= length {
+			msgutil.SendResponse(client, message.Source, message.Target, s)
+		}
+	}
+	return true
+}
+
+// clockCountdown announces a start time, so everyone can press play at the
+// same moment even if messages arrive late, then ticks down to it. Each tick
+// is timed from the start time so delays don't add up.
+func clockCountdown(ctx context.Context, client *ircutil.Client,
+	message *ircutil.Message, settings countdownSettings) bool {
+	// Send response with start time, rounded up to a whole second.
+	now := clockutil.Now()
+	start := now.Add(time.Duration(settings.lead) * time.Second).
+		Truncate(time.Second)
+	if start.Before(now.Add(time.Duration(settings.lead) * time.Second)) {
+		start = start.Add(time.Second)
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Press play at %s, %ds from now",
+			start.UTC().Format("15:04:05 MST"),
+			int(start.Sub(now).Seconds()+0.5)))
+
+	// Send response with seconds remaining at each second before the start,
+	// and "Start!" at the start.
+	length := settings.length
+	if length > settings.lead {
+		length = settings.lead
+	}
+	for i := length; i >= 0; i-- {
+		tick := start.Add(-time.Duration(i) * time.Second)
+		select {
+		case <-ctx.Done():
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Countdown stopped")
+			return false
+		case <-clockutil.After(tick.Sub(clockutil.Now())):
+		}
+		s := strconv.Itoa(i)
+		if i == 0 {
+			s = "Start!"
+		}
+		msgutil.SendResponse(client, message.Source, message.Target, s)
+	}
+	return true
+}
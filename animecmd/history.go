@@ -0,0 +1,182 @@
+package animecmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+const historyKey = "anime/history"
+
+// maxHistory is how many history entries are kept for each alias.
+const maxHistory = 50
+
+// historyShown is how many recent history entries History sends.
+const historyShown = 5
+
+// historyMu makes sure entries added at the same time aren't lost.
+var historyMu sync.Mutex
+
+// historyEntry stores an episode that was watched or set manually.
+type historyEntry struct {
+	Time         int64    `json:"time"`
+	Show         string   `json:"show"`
+	Episode      int      `json:"episode"`
+	Title        string   `json:"title,omitempty"`
+	Participants []string `json:"participants,omitempty"`
+	Manual       bool     `json:"manual,omitempty"`
+}
+
+// getHistory gets the history entries of an alias in a message's scope, oldest
+// first.
+func getHistory(client *ircutil.Client, message *ircutil.Message,
+	alias string) ([]historyEntry, error) {
+	keys := []string{"", "", historyKey, alias}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+	value, err := configutil.GetValue(client, keys)
+	if err != nil || len(value) < 1 {
+		return nil, err
+	}
+	var entries []historyEntry
+	err = json.Unmarshal([]byte(value), &entries)
+	return entries, err
+}
+
+// addHistory records a history entry for an alias in a message's scope,
+// dropping the oldest entries past the maximum.
+func addHistory(client *ircutil.Client, message *ircutil.Message,
+	alias string, entry historyEntry) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	entries, err := getHistory(client, message, alias)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+	}
+	entry.Time = clockutil.Now().Unix()
+	entries = append(entries, entry)
+	if len(entries) > maxHistory {
+		entries = entries[len(entries)-maxHistory:]
+	}
+
+	// Store entries as JSON in persistent data.
+	raw, _ := json.Marshal(entries)
+	keys := []string{"", "", historyKey, alias}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+	if err = configutil.SetValue(client, keys, string(raw)); err != nil {
+		ircutil.Log(client, err.Error())
+	}
+}
+
+// History sends the most recent history entries of an alias.
+// Function key: inami/animecmd.History
+func History(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	alias := configutil.GetArgs(command, message)["alias"]
+	entries, err := getHistory(client, message, alias)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error getting history, try again later")
+		return
+	}
+	if len(entries) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Nothing has been watched for %s yet", alias))
+		return
+	}
+
+	// Send response with recent entries, newest first.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Recent history for %s:", alias))
+	if len(entries) > historyShown {
+		entries = entries[len(entries)-historyShown:]
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			formatEntry(entries[i]))
+	}
+}
+
+// LastWatched sends when an alias was last watched, or when anything was last
+// watched if no alias is given. Entries set manually are skipped.
+// Function key: inami/animecmd.LastWatched
+func LastWatched(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Get aliases to check, which is every alias with history if none is given.
+	aliases := []string{configutil.GetArgs(command, message)["alias"]}
+	if len(aliases[0]) < 1 {
+		keys := []string{"", "", historyKey}
+		configutil.UpdateScope(keys, message.Source, message.Target)
+		values, _ := configutil.GetGroup(client, keys)
+		aliases = aliases[:0]
+		for a := range values {
+			aliases = append(aliases, a)
+		}
+		sort.Strings(aliases)
+	}
+
+	// Find the latest watched entry across aliases.
+	var last historyEntry
+	for _, a := range aliases {
+		entries, err := getHistory(client, message, a)
+		if err != nil {
+			ircutil.Log(client, err.Error())
+			continue
+		}
+		for _, e := range entries {
+			if !e.Manual && e.Time >= last.Time {
+				last = e
+			}
+		}
+	}
+
+	// Send response with the latest entry.
+	if last.Time == 0 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Nothing has been watched yet")
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Last watched %s Episode %d%s %s", last.Show, last.Episode,
+			Episode{Title: last.Title}.quotedTitle(),
+			formatAgo(time.Unix(last.Time, 0))))
+}
+
+// formatEntry formats a history entry for a response.
+func formatEntry(e historyEntry) string {
+	when := time.Unix(e.Time, 0).UTC().Format("2006-01-02 15:04 MST")
+	who := ""
+	if e.Manual && len(e.Participants) > 0 {
+		who = fmt.Sprintf(" (set by %s)", e.Participants[0])
+	} else if len(e.Participants) > 0 {
+		who = fmt.Sprintf(" with %s", strings.Join(e.Participants, ", "))
+	}
+	return fmt.Sprintf("%s: %s Episode %d%s%s", when, e.Show, e.Episode,
+		Episode{Title: e.Title}.quotedTitle(), who)
+}
+
+// formatAgo formats how long ago a time was, in the largest whole unit.
+func formatAgo(t time.Time) string {
+	d := clockutil.Now().Sub(t)
+	n, unit := int(d.Hours()/24), "day"
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d.Minutes()), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d.Hours()), "hour"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
@@ -0,0 +1,54 @@
+package animecmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+)
+
+func TestFormatAgo(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	defer clockutil.Set(clockutil.NewFake(now))()
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{0, "just now"},
+		{59 * time.Second, "just now"},
+		{time.Minute, "1 minute ago"},
+		{59*time.Minute + 59*time.Second, "59 minutes ago"},
+		{time.Hour, "1 hour ago"},
+		{23 * time.Hour, "23 hours ago"},
+		{24 * time.Hour, "1 day ago"},
+		{10 * 24 * time.Hour, "10 days ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAgo(now.Add(-tt.ago)); got != tt.want {
+			t.Errorf("formatAgo(%s ago) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+}
+
+func TestFormatEntry(t *testing.T) {
+	at := time.Date(2024, 1, 10, 12, 30, 0, 0, time.UTC).Unix()
+	tests := []struct {
+		entry historyEntry
+		want  string
+	}{
+		{historyEntry{Time: at, Show: "Frieren", Episode: 1,
+			Title: "The Journey's End", Participants: []string{"alice", "bob"}},
+			"2024-01-10 12:30 UTC: Frieren Episode 1 \"The Journey's End\" " +
+				"with alice, bob"},
+		{historyEntry{Time: at, Show: "Frieren", Episode: 2},
+			"2024-01-10 12:30 UTC: Frieren Episode 2"},
+		{historyEntry{Time: at, Show: "Frieren", Episode: 3,
+			Participants: []string{"carol"}, Manual: true},
+			"2024-01-10 12:30 UTC: Frieren Episode 3 (set by carol)"},
+	}
+	for _, tt := range tests {
+		if got := formatEntry(tt.entry); got != tt.want {
+			t.Errorf("formatEntry(%+v) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package animecmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/webutil"
+)
+
+// jikan fetches MyAnimeList data from the Jikan API.
+type jikan struct {
+	api *webutil.Client
+}
+
+// jikanAnime stores a show returned by the Jikan API.
+type jikanAnime struct {
+	ID       int    `json:"mal_id"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Airing   bool   `json:"airing"`
+	Duration string `json:"duration"`
+	Episodes int    `json:"episodes"`
+}
+
+// jikanDuration matches the parts of an episode duration such as
+// "1 hr 5 min per ep".
+var jikanDuration = regexp.MustCompile(`(\d+) (hr|min|sec)`)
+
+// show converts a Jikan show to show data.
+func (a jikanAnime) show() Show {
+	// Add up each part of the episode duration.
+	var length time.Duration
+	units := map[string]time.Duration{
+		"hr":  time.Hour,
+		"min": time.Minute,
+		"sec": time.Second,
+	}
+	for _, m := range jikanDuration.FindAllStringSubmatch(a.Duration, -1) {
+		n, _ := strconv.Atoi(m[1])
+		length += time.Duration(n) * units[m[2]]
+	}
+
+	return Show{
+		ID:       strconv.Itoa(a.ID),
+		Title:    a.Title,
+		URL:      a.URL,
+		Airing:   a.Airing,
+		Length:   length,
+		Episodes: a.Episodes,
+	}
+}
+
+// Search queries the Jikan API and returns search results as a slice.
+func (j jikan) Search(query string) ([]Show, error) {
+	data := struct {
+		Data []jikanAnime `json:"data"`
+	}{}
+	err := j.api.Get(fmt.Sprintf("/anime?limit=5&q=%s",
+		url.QueryEscape(query)), &data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return slice of shows.
+	var shows []Show
+	for _, a := range data.Data {
+		shows = append(shows, a.show())
+	}
+	return shows, nil
+}
+
+// Show queries the Jikan API and returns show data.
+func (j jikan) Show(id string) (Show, error) {
+	data := struct {
+		Data jikanAnime `json:"data"`
+	}{}
+	err := j.api.Get(fmt.Sprintf("/anime/%s", url.PathEscape(id)), &data)
+	if webutil.IsStatus(err, http.StatusNotFound) {
+		return Show{}, nil
+	}
+	if err != nil || data.Data.ID == 0 {
+		return Show{}, err
+	}
+	return data.Data.show(), nil
+}
+
+// Episodes queries the Jikan API and returns episode data.
+func (j jikan) Episodes(id string) ([]Episode, error) {
+	// Initiate loop to hit all pages.
+	var episodes []Episode
+	for page, more := 1, true; more; page++ {
+		data := struct {
+			Data []struct {
+				Number int    `json:"mal_id"`
+				Title  string `json:"title"`
+				Aired  string `json:"aired"`
+			} `json:"data"`
+			Pagination struct {
+				More bool `json:"has_next_page"`
+			} `json:"pagination"`
+		}{}
+		err := j.api.Get(fmt.Sprintf("/anime/%s/episodes?page=%d",
+			url.PathEscape(id), page), &data)
+		if err != nil {
+			return nil, err
+		}
+
+		// Update episodes slice.
+		for _, e := range data.Data {
+			aired, _ := time.Parse(time.RFC3339, e.Aired)
+			episodes = append(episodes, Episode{
+				Number: e.Number,
+				Title:  e.Title,
+				Aired:  aired,
+			})
+		}
+		more = data.Pagination.More
+	}
+	return episodes, nil
+}
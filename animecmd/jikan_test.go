@@ -0,0 +1,27 @@
+package animecmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJikanDuration(t *testing.T) {
+	tests := []struct {
+		duration string
+		want     time.Duration
+	}{
+		{"24 min per ep", 24 * time.Minute},
+		{"1 hr 5 min per ep", time.Hour + 5*time.Minute},
+		{"2 hr", 2 * time.Hour},
+		{"3 min 30 sec per ep", 3*time.Minute + 30*time.Second},
+		{"45 sec per ep", 45 * time.Second},
+		{"Unknown", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		got := jikanAnime{Duration: tt.duration}.show().Length
+		if got != tt.want {
+			t.Errorf("duration %q = %s, want %s", tt.duration, got, tt.want)
+		}
+	}
+}
@@ -1,15 +1,18 @@
 package animecmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"net/url"
+	"time"
 
-	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/jasonpuglisi/inami-irc-bot/webutil"
 )
 
+// kitsu fetches data from the Kitsu Edge API.
+type kitsu struct {
+	api *webutil.Client
+}
+
 // container stores data returned by the Kitsu API.
 type container struct {
 	Results []result `json:"data"`
@@ -24,9 +27,13 @@ type result struct {
 
 // attributes stores data in an entry returned by the Kitsu API.
 type attributes struct {
-	Slug   string `json:"slug"`
-	Title  string `json:"canonicalTitle"`
-	Number int    `json:"number"`
+	Slug    string `json:"slug"`
+	Title   string `json:"canonicalTitle"`
+	Status  string `json:"status"`
+	Length  int    `json:"episodeLength"`
+	Count   int    `json:"episodeCount"`
+	Number  int    `json:"number"`
+	Airdate string `json:"airdate"`
 }
 
 // links stores references to additional data returned by query.
@@ -34,111 +41,94 @@ type links struct {
 	Next string `json:"next"`
 }
 
-// search queries the Kitsu API and returns search results as a slice.
-func search(query string) ([]result, error) {
-	// Escape query string and send it to Kitsu API.
-	resp, err := http.Get(
-		fmt.Sprintf("https://kitsu.io/api/edge/anime?page[limit]=5&filter[text]=%s",
-			url.QueryEscape(query)))
-	if err != nil {
-		return nil, err
+// show converts a Kitsu entry to show data.
+func (r result) show() Show {
+	return Show{
+		ID:       r.ID,
+		Title:    r.Attributes.Title,
+		URL:      fmt.Sprintf("https://kitsu.io/anime/%s", r.Attributes.Slug),
+		Airing:   r.Attributes.Status == "current",
+		Length:   time.Duration(r.Attributes.Length) * time.Minute,
+		Episodes: r.Attributes.Count,
 	}
-	defer resp.Body.Close()
+}
 
-	// Read data body into json string.
-	body, err := ioutil.ReadAll(resp.Body)
+// Search queries the Kitsu API and returns search results as a slice.
+func (k kitsu) Search(query string) ([]Show, error) {
+	// Escape query string and send it to Kitsu API.
+	data := container{}
+	err := k.api.Get(fmt.Sprintf("/anime?page[limit]=5&filter[text]=%s",
+		url.QueryEscape(query)), &data)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse json string into container struct.
-	data := container{}
-	json.Unmarshal(body, &data)
-
-	// Return slice of results with a maximum of five elements.
-	if len(data.Results) < 5 {
-		return data.Results, nil
+	// Return slice of shows with a maximum of five elements.
+	var shows []Show
+	for i, r := range data.Results {
+		if i == 5 {
+			break
+		}
+		shows = append(shows, r.show())
 	}
-	return data.Results[:5], nil
+	return shows, nil
 }
 
-// show queries the Kitsu API and returns show data.
-func show(id string) (result, error) {
+// Show queries the Kitsu API and returns show data.
+func (k kitsu) Show(id string) (Show, error) {
 	// Escape query string and send it to Kitsu API for show data.
-	resp, err := http.Get(
-		fmt.Sprintf("https://kitsu.io/api/edge/anime?page[limit]=1&filter[id]=%s",
-			url.QueryEscape(id)))
+	data := container{}
+	err := k.api.Get(fmt.Sprintf("/anime?page[limit]=1&filter[id]=%s",
+		url.QueryEscape(id)), &data)
 	if err != nil {
-		return result{}, err
+		return Show{}, err
 	}
-	defer resp.Body.Close()
 
-	// Read data body into json string.
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return result{}, err
+	// Return struct of show, or an empty one if it wasn't found.
+	if len(data.Results) < 1 {
+		return Show{}, nil
 	}
+	return data.Results[0].show(), nil
+}
 
-	// Parse json string into container struct.
+// Slug queries the Kitsu API for a show by the name in its URL and returns
+// show data.
+func (k kitsu) Slug(slug string) (Show, error) {
 	data := container{}
-	json.Unmarshal(body, &data)
-
-	// Return struct of show.
-	return data.Results[0], nil
+	err := k.api.Get(fmt.Sprintf("/anime?page[limit]=1&filter[slug]=%s",
+		url.QueryEscape(slug)), &data)
+	if err != nil || len(data.Results) < 1 {
+		return Show{}, err
+	}
+	return data.Results[0].show(), nil
 }
 
-// episodes queries the Kitsu API and returns episode data.
-func episodes(id string) ([]result, error) {
+// Episodes queries the Kitsu API and returns episode data.
+func (k kitsu) Episodes(id string) ([]Episode, error) {
 	// Create slice to store results.
-	var results []result
-
-	// Connect to memcached and attempt to return episodes from cache.
-	mc := memcache.New("127.0.0.1:11211")
-	it, err := mc.Get(fmt.Sprintf("episodes_%s", id))
-	if err == nil {
-		err = json.Unmarshal(it.Value, &results)
-		if err == nil {
-			return results, nil
-		}
-	}
+	var results []Episode
 
 	// Initiate loop to hit all pages.
-	more, link := true, fmt.Sprintf(
-		"https://kitsu.io/api/edge/anime/%s/episodes?page[limit]=20",
-		url.QueryEscape(id))
-	for more {
-		// Escape query string and send it to Kitsu API.
-		resp, err2 := http.Get(link)
-		if err2 != nil {
-			return nil, err2
-		}
-		defer resp.Body.Close()
-
-		// Read data body into json string.
-		body, err2 := ioutil.ReadAll(resp.Body)
-		if err2 != nil {
-			return nil, err2
-		}
-
-		// Parse json string into container struct.
+	link := fmt.Sprintf("/anime/%s/episodes?page[limit]=20",
+		url.PathEscape(id))
+	for len(link) > 0 {
+		// Send request to Kitsu API.
 		data := container{}
-		json.Unmarshal(body, &data)
-
-		// Update results slice.
-		results = append(results, data.Results...)
-
-		if data.Links.Next != "" {
-			link = data.Links.Next
-		} else {
-			more = false
+		err := k.api.Get(link, &data)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	// Send episodes to cache with expiry of 12 hours.
-	episodes, err := json.Marshal(results)
-	if err == nil {
-		mc.Set(&memcache.Item{Key: fmt.Sprintf("episodes_%s", id), Value: episodes})
-		mc.Touch(fmt.Sprintf("episodes_%s", id), 43200)
+		// Update results slice and move on to the next page.
+		for _, r := range data.Results {
+			aired, _ := time.Parse("2006-01-02", r.Attributes.Airdate)
+			results = append(results, Episode{
+				Number: r.Attributes.Number,
+				Title:  r.Attributes.Title,
+				Aired:  aired,
+			})
+		}
+		link = data.Links.Next
 	}
 
 	// Return slice of episodes.
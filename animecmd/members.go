@@ -0,0 +1,166 @@
+package animecmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// membersKey is the prefix of the data groups storing individual progress for
+// a channel's shows. Each alias has its own group, keyed by nickname.
+const membersKey = "anime/members/"
+
+// getMembers gets the individual progress of everyone tracked for a channel's
+// alias, keyed by nickname.
+func getMembers(client *ircutil.Client, channel, alias string) map[string]int {
+	values, _ := configutil.GetGroup(client,
+		[]string{"channel", channel, membersKey + alias})
+	members := map[string]int{}
+	for nick, value := range values {
+		num, _ := strconv.Atoi(value)
+		members[nick] = num
+	}
+	return members
+}
+
+// setMember sets someone's individual progress for a channel's alias.
+func setMember(client *ircutil.Client, channel, alias, nick string,
+	num int) {
+	err := configutil.SetValue(client,
+		[]string{"channel", channel, membersKey + alias, nick}, strconv.Itoa(num))
+	if err != nil {
+		ircutil.Log(client, err.Error())
+	}
+}
+
+// advanceMembers raises the individual progress of each participant to an
+// episode number, leaving anyone who's already further ahead alone.
+func advanceMembers(client *ircutil.Client, channel, alias string,
+	nicks []string, num int) {
+	members := getMembers(client, channel, alias)
+	for _, n := range nicks {
+		if members[n] < num {
+			setMember(client, channel, alias, n, num)
+		}
+	}
+}
+
+// clearMembers removes everyone's individual progress for a channel's alias.
+func clearMembers(client *ircutil.Client, channel, alias string) {
+	for nick := range getMembers(client, channel, alias) {
+		configutil.DeleteValue(client,
+			[]string{"channel", channel, membersKey + alias, nick})
+	}
+}
+
+// getGroupProgress gets the episode number a channel's alias is on.
+func getGroupProgress(client *ircutil.Client, channel, alias string) int {
+	value, _ := configutil.GetValue(client,
+		[]string{"channel", channel, progressKey, alias})
+	num, _ := strconv.Atoi(value)
+	return num
+}
+
+// MyProgress shows or updates the message source's own progress for a
+// channel's show, so they can track being ahead of or behind the group.
+// Function key: inami/animecmd.MyProgress
+func MyProgress(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	if !ircutil.IsChannel(message.Target) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Individual progress is only tracked in channels")
+		return
+	}
+
+	// Get show id from alias in persistent data to make sure it exists.
+	args := configutil.GetArgs(command, message)
+	alias, nick := args["alias"], ircutil.GetNick(message.Source)
+	if _, _, ok := getShowID(client, message, alias); !ok {
+		return
+	}
+	group := getGroupProgress(client, message.Target, alias)
+
+	// Send response with current progress if no episode number was given.
+	if len(args["episode"]) < 1 {
+		num, ok := getMembers(client, message.Target, alias)[nick]
+		text := fmt.Sprintf("You're caught up, everyone's watched %d", group)
+		if !ok {
+			text = "You haven't watched any of it with the group yet"
+		} else if num != group {
+			text = fmt.Sprintf("You've watched %d, everyone's watched %d", num,
+				group)
+		}
+		msgutil.SendResponse(client, message.Source, message.Target, text)
+		return
+	}
+
+	// Parse episode number, set it, and send response with confirmation.
+	num, err := strconv.Atoi(args["episode"])
+	if err != nil || num < 0 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Invalid episode number")
+		return
+	}
+	setMember(client, message.Target, alias, nick, num)
+	text := "you're caught up"
+	if num < group {
+		text = fmt.Sprintf("you're %d behind", group-num)
+	} else if num > group {
+		text = fmt.Sprintf("you're %d ahead", num-group)
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Updated your progress to %d, %s", num, text))
+}
+
+// Behind lists everyone whose own progress for a channel's show is behind the
+// group's.
+// Function key: inami/animecmd.Behind
+func Behind(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	if !ircutil.IsChannel(message.Target) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Individual progress is only tracked in channels")
+		return
+	}
+
+	// Get show id from alias in persistent data to make sure it exists.
+	alias := configutil.GetArgs(command, message)["alias"]
+	if _, _, ok := getShowID(client, message, alias); !ok {
+		return
+	}
+
+	// Collect everyone who's behind, furthest behind first.
+	group := getGroupProgress(client, message.Target, alias)
+	members := getMembers(client, message.Target, alias)
+	var nicks []string
+	for n, num := range members {
+		if num < group {
+			nicks = append(nicks, n)
+		}
+	}
+	sort.Slice(nicks, func(i, j int) bool {
+		if members[nicks[i]] != members[nicks[j]] {
+			return members[nicks[i]] < members[nicks[j]]
+		}
+		return nicks[i] < nicks[j]
+	})
+
+	// Send response with who needs to catch up.
+	if len(nicks) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Everyone's caught up on %s", alias))
+		return
+	}
+	var behind []string
+	for _, n := range nicks {
+		behind = append(behind, fmt.Sprintf("%s (%d)", n, members[n]))
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Catching up to episode %d of %s: %s", group, alias,
+			strings.Join(behind, ", ")))
+}
@@ -0,0 +1,378 @@
+package animecmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// episodeLength is how long a watch party plays for after its countdown when
+// the provider doesn't know a show's episode length.
+const episodeLength = 24 * time.Minute
+
+// maxMarathon is the most episodes a single watch party can play.
+var maxMarathon = 12
+
+// readyTimeout is how long a watch party waits for everyone to be ready before
+// starting anyway.
+var readyTimeout = 2 * time.Minute
+
+// Watch party phases.
+const (
+	phaseWaiting  = "waiting"
+	phaseCounting = "counting"
+	phasePlaying  = "playing"
+	phasePaused   = "paused"
+)
+
+// party stores the state of a watch party. Commands update it and send events
+// to the goroutine running the party. Its context is cancelled when the party
+// is cancelled, replaced, or the bot shuts down.
+type party struct {
+	host   string
+	phase  string
+	joined map[string]bool
+	events chan string
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// parties maps session keys to active watch parties.
+var parties = map[string]*party{}
+
+// partiesMu guards parties and the state of each party.
+var partiesMu sync.Mutex
+
+// partyKey returns the key of a message's watch party.
+func partyKey(client *ircutil.Client, message *ircutil.Message) string {
+	return fmt.Sprintf("%s %s", ircutil.GetClientPrefix(client),
+		strings.ToLower(sessionTarget(message)))
+}
+
+// openParty creates a watch party for a message's target, hosted by the
+// message source, and returns a function that removes it. A party that's
+// still playing for the target is cancelled.
+func openParty(client *ircutil.Client, message *ircutil.Message) (*party,
+	func()) {
+	partiesMu.Lock()
+	defer partiesMu.Unlock()
+	host := ircutil.GetNick(message.Source)
+	ctx, cancel := context.WithCancel(cmdutil.Context())
+	p := &party{
+		host:   host,
+		phase:  phaseWaiting,
+		joined: map[string]bool{host: false},
+		events: make(chan string, 16),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	key := partyKey(client, message)
+	if old, ok := parties[key]; ok {
+		old.cancel()
+	}
+	parties[key] = p
+	return p, func() {
+		partiesMu.Lock()
+		defer partiesMu.Unlock()
+		cancel()
+		if parties[key] == p {
+			delete(parties, key)
+		}
+	}
+}
+
+// cancelParty cancels the watch party for a message's target, and returns
+// false if there isn't one.
+func cancelParty(client *ircutil.Client, message *ircutil.Message) bool {
+	partiesMu.Lock()
+	defer partiesMu.Unlock()
+	p, ok := parties[partyKey(client, message)]
+	if ok {
+		p.cancel()
+	}
+	return ok
+}
+
+// send passes an event to the goroutine running a party. Events are dropped if
+// the party is too busy to keep up.
+func (p *party) send(event string) {
+	select {
+	case p.events <- event:
+	default:
+	}
+}
+
+// readyCount returns how many joined participants are ready, and how many
+// have joined. partiesMu must be held.
+func (p *party) readyCount() (int, int) {
+	n := 0
+	for _, r := range p.joined {
+		if r {
+			n++
+		}
+	}
+	return n, len(p.joined)
+}
+
+// participants returns the sorted nicknames of everyone who joined a party.
+func (p *party) participants() []string {
+	partiesMu.Lock()
+	defer partiesMu.Unlock()
+	var nicks []string
+	for n := range p.joined {
+		nicks = append(nicks, n)
+	}
+	sort.Strings(nicks)
+	return nicks
+}
+
+// getParty returns the watch party for a message's target if it's in one of
+// the given phases, and sends a response if it isn't. partiesMu is held when
+// it returns a party, and must be unlocked by the caller.
+func getParty(client *ircutil.Client, message *ircutil.Message,
+	phases ...string) *party {
+	partiesMu.Lock()
+	p := parties[partyKey(client, message)]
+	if p != nil {
+		for _, phase := range phases {
+			if p.phase == phase {
+				return p
+			}
+		}
+	}
+	partiesMu.Unlock()
+
+	// Send response for the phase that was expected.
+	text := "No watch party is waiting here"
+	if phases[0] == phasePlaying {
+		text = "Nothing is playing here"
+	} else if phases[0] == phasePaused {
+		text = "Nothing is paused here"
+	}
+	msgutil.SendResponse(client, message.Source, message.Target, text)
+	return nil
+}
+
+// Ready marks the message source as ready in a waiting watch party, joining it
+// if they haven't already.
+// Function key: inami/animecmd.Ready
+func Ready(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	setReady(client, message, true)
+}
+
+// Unready marks the message source as not ready in a waiting watch party,
+// joining it if they haven't already, so the party waits for them.
+// Function key: inami/animecmd.Unready
+func Unready(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	setReady(client, message, false)
+}
+
+// setReady updates the message source's ready state in a waiting watch party,
+// and sends a response with how many participants are ready.
+func setReady(client *ircutil.Client, message *ircutil.Message,
+	ready bool) {
+	p := getParty(client, message, phaseWaiting)
+	if p == nil {
+		return
+	}
+	nick := ircutil.GetNick(message.Source)
+	p.joined[nick] = ready
+	n, total := p.readyCount()
+	partiesMu.Unlock()
+
+	// Send response with ready count before the party reacts to it.
+	status := "is ready"
+	if !ready {
+		status = "needs a moment"
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("%s %s (%d/%d ready)", nick, status, n, total))
+	p.send("ready")
+}
+
+// Start starts a waiting watch party's countdown without waiting for everyone
+// to be ready. Only the host or a moderator can use it.
+// Function key: inami/animecmd.Start
+func Start(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	p := getParty(client, message, phaseWaiting)
+	if p == nil {
+		return
+	}
+	host := p.host
+	partiesMu.Unlock()
+	if ircutil.GetNick(message.Source) != host &&
+		!cmdutil.HasRole(client, message, "moderator") {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Only the host or a moderator can start early")
+		return
+	}
+	p.send("start")
+}
+
+// Pause pauses a playing watch party with a countdown so everyone pauses at
+// the same time.
+// Function key: inami/animecmd.Pause
+func Pause(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	p := getParty(client, message, phasePlaying)
+	if p == nil {
+		return
+	}
+	partiesMu.Unlock()
+	p.send("pause")
+}
+
+// Resume resumes a paused watch party with a countdown so everyone presses
+// play at the same time.
+// Function key: inami/animecmd.Resume
+func Resume(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	p := getParty(client, message, phasePaused)
+	if p == nil {
+		return
+	}
+	partiesMu.Unlock()
+	p.send("resume")
+}
+
+// setPhase changes a party's phase.
+func (p *party) setPhase(phase string) {
+	partiesMu.Lock()
+	defer partiesMu.Unlock()
+	p.phase = phase
+}
+
+// waitReady waits until everyone who joined a party is ready, the host or a
+// moderator starts it, or the ready timeout passes. It returns false if the
+// party was cancelled.
+func waitReady(ctx context.Context, client *ircutil.Client,
+	message *ircutil.Message, p *party) bool {
+	timeout := clockutil.After(readyTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Watch party cancelled")
+			return false
+		case <-timeout:
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Starting without everyone")
+			return true
+		case e := <-p.events:
+			if e == "start" {
+				return true
+			}
+			partiesMu.Lock()
+			n, total := p.readyCount()
+			partiesMu.Unlock()
+			if e == "ready" && n == total {
+				msgutil.SendResponse(client, message.Source, message.Target,
+					"Everyone's ready")
+				return true
+			}
+		}
+	}
+}
+
+// intermission waits between episodes of a marathon for the intermission
+// length of the message's scope, or until the host or a moderator starts the
+// next episode early. It returns false if the party was cancelled.
+func intermission(ctx context.Context, client *ircutil.Client,
+	message *ircutil.Message, p *party, show Show, next Episode) bool {
+	minutes := getCountdownSettings(client, message).intermission
+	p.setPhase(phaseWaiting)
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Intermission, %s Episode %d%s starts in %d minute%s",
+			show.Title, next.Number, next.quotedTitle(), minutes,
+			plural(minutes)))
+	timeout := clockutil.After(time.Duration(minutes) * time.Minute)
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timeout:
+			return true
+		case e := <-p.events:
+			if e == "start" {
+				return true
+			}
+		}
+	}
+}
+
+// play waits for a party's episode to finish, handling pauses and resumes
+// along the way. Paused time doesn't count towards the episode's length.
+func play(ctx context.Context, client *ircutil.Client,
+	message *ircutil.Message, p *party, length time.Duration) {
+	p.setPhase(phasePlaying)
+	remaining := length
+	for remaining > 0 {
+		// Wait for the episode to end or a pause.
+		started := clockutil.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case <-clockutil.After(remaining):
+			return
+		case e := <-p.events:
+			remaining -= clockutil.Now().Sub(started)
+			if e != "pause" {
+				continue
+			}
+		}
+
+		// Count down to pausing, then wait for a resume.
+		p.setPhase(phaseCounting)
+		if !syncCountdown(ctx, client, message, "Pausing", "Pause!") {
+			return
+		}
+		p.setPhase(phasePaused)
+		for resumed := false; !resumed; {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-p.events:
+				resumed = e == "resume"
+			}
+		}
+
+		// Count down to resuming.
+		p.setPhase(phaseCounting)
+		if !syncCountdown(ctx, client, message, "Resuming", "Play!") {
+			return
+		}
+		p.setPhase(phasePlaying)
+	}
+}
+
+// syncCountdown sends a short countdown so everyone in a party acts at the
+// same time. It returns false if the context was cancelled first.
+func syncCountdown(ctx context.Context, client *ircutil.Client,
+	message *ircutil.Message, action, final string) bool {
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("%s in 3", action))
+	for i := 2; i >= 0; i-- {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-clockutil.After(time.Second):
+		}
+		s := fmt.Sprintf("%d", i)
+		if i == 0 {
+			s = final
+		}
+		msgutil.SendResponse(client, message.Source, message.Target, s)
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package animecmd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// pickWindow is how long a user has to pick a search result for an alias.
+const pickWindow = time.Minute
+
+// pick stores search results waiting for a user to choose one for an alias.
+type pick struct {
+	alias    string
+	provider string
+	shows    []Show
+	expires  time.Time
+}
+
+// picks maps client prefixes, targets, and nicknames to the search results
+// waiting for that user to pick one.
+var picks = map[string]pick{}
+
+// picksMu guards picks.
+var picksMu sync.Mutex
+
+// pickKey returns the key of a message source's pending pick in a target.
+func pickKey(client *ircutil.Client, message *ircutil.Message) string {
+	return fmt.Sprintf("%s %s %s", ircutil.GetClientPrefix(client),
+		strings.ToLower(message.Target),
+		strings.ToLower(ircutil.GetNick(message.Source)))
+}
+
+// Pick assigns a result from an alias search to the alias.
+// Function key: inami/animecmd.Pick
+func Pick(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Get pending pick for the message source.
+	key := pickKey(client, message)
+	picksMu.Lock()
+	p, ok := picks[key]
+	if ok && !clockutil.Now().Before(p.expires) {
+		delete(picks, key)
+		ok = false
+	}
+	picksMu.Unlock()
+	if !ok {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Nothing to pick from, search for a show with alias first")
+		return
+	}
+
+	// Parse result number from arguments.
+	num, err := strconv.Atoi(configutil.GetArgs(command, message)["number"])
+	if err != nil || num < 1 || num > len(p.shows) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Pick a number from 1 to %d", len(p.shows)))
+		return
+	}
+
+	// Remove pending pick and assign chosen show to alias.
+	picksMu.Lock()
+	delete(picks, key)
+	picksMu.Unlock()
+	saveAlias(client, message, p.alias, p.provider, p.shows[num-1])
+}
+
+// offerPicks sends numbered search results, and lets the message source pick
+// one for an alias within the pick window.
+func offerPicks(client *ircutil.Client, message *ircutil.Message, alias,
+	provider string, shows []Show) {
+	picksMu.Lock()
+	picks[pickKey(client, message)] = pick{
+		alias:    alias,
+		provider: provider,
+		shows:    shows,
+		expires:  clockutil.Now().Add(pickWindow),
+	}
+	picksMu.Unlock()
+
+	// Send response with numbered results and how to pick one.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Shows found on %s:", provider))
+	for i, s := range shows {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("%d. %s", i+1, s.Title))
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Choose one for %s with %s <number> within a minute", alias,
+			commandTrigger(client, "inami/animecmd.Pick", "pick")))
+}
+
+// identifyShow looks up a show from a single word, which can be a provider id
+// (optionally prefixed with a provider name), a show URL, or a Kitsu slug. It
+// returns the provider name and show, or a show with an empty id if the word
+// doesn't identify one.
+func identifyShow(provider, word string) (string, Show, error) {
+	// Work out which provider and id or slug the word refers to.
+	id, slug := "", ""
+	if p, ref, isSlug, ok := parseShowURL(word); ok {
+		provider = p
+		if isSlug {
+			slug = ref
+		} else {
+			id = ref
+		}
+	} else if p, ref := parseShowID(word, ""); len(p) > 0 {
+		provider, id = p, ref
+	} else if _, err := strconv.Atoi(word); err == nil {
+		id = word
+	} else {
+		slug = word
+	}
+
+	// Fetch show by id or slug.
+	if len(id) > 0 {
+		show, err := providers[provider].Show(id)
+		return provider, show, err
+	}
+	p, ok := providers[provider].(slugProvider)
+	if !ok {
+		return provider, Show{}, nil
+	}
+	show, err := p.Slug(strings.ToLower(slug))
+	return provider, show, err
+}
+
+// parseShowURL gets the provider name and id or slug from a show's URL on a
+// provider's website. It returns false if the URL isn't a show URL.
+func parseShowURL(raw string) (provider, ref string, isSlug, ok bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", "", false, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "anime" || len(parts[1]) < 1 {
+		return "", "", false, false
+	}
+	_, err = strconv.Atoi(parts[1])
+	numeric := err == nil
+
+	// Match host to provider.
+	switch strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.") {
+	case "kitsu.io", "kitsu.app":
+		return "kitsu", parts[1], !numeric, true
+	case "anilist.co":
+		return "anilist", parts[1], false, numeric
+	case "myanimelist.net":
+		return "mal", parts[1], false, numeric
+	}
+	return "", "", false, false
+}
+
+// saveAlias assigns a show to an alias in the message's scope, resets its
+// episode progress (including everyone's own progress in a channel), and
+// sends a response with confirmation.
+func saveAlias(client *ircutil.Client, message *ircutil.Message, alias,
+	provider string, show Show) {
+	// Update scope/owner to match command scope.
+	keys := []string{"", "", "anime/shows", alias}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+
+	// Set alias, intialize episode progress, and send response with
+	// confirmation.
+	err := configutil.SetValue(client, keys, formatShowID(provider, show.ID))
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error setting alias, try again later")
+		return
+	}
+	keys[2] = progressKey
+	configutil.SetValue(client, keys, "0")
+	if ircutil.IsChannel(message.Target) {
+		clearMembers(client, message.Target, alias)
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Aliased %s to %s", show.Title, alias))
+}
@@ -0,0 +1,38 @@
+package animecmd
+
+import "testing"
+
+func TestParseShowURL(t *testing.T) {
+	tests := []struct {
+		raw      string
+		provider string
+		ref      string
+		isSlug   bool
+		ok       bool
+	}{
+		{"https://kitsu.io/anime/frieren", "kitsu", "frieren", true, true},
+		{"https://kitsu.app/anime/46474/episodes", "kitsu", "46474", false,
+			true},
+		{"https://www.KITSU.io/anime/frieren/", "kitsu", "frieren", true, true},
+		{"https://anilist.co/anime/154587/Sousou-no-Frieren/", "anilist",
+			"154587", false, true},
+		{"https://myanimelist.net/anime/52991/Sousou_no_Frieren", "mal",
+			"52991", false, true},
+		{"https://anilist.co/anime/frieren", "", "", false, false},
+		{"https://myanimelist.net/manga/126287", "", "", false, false},
+		{"https://kitsu.io/anime", "", "", false, false},
+		{"https://example.com/anime/1", "", "", false, false},
+		{"kitsu.io/anime/frieren", "", "", false, false},
+		{"frieren", "", "", false, false},
+		{"86", "", "", false, false},
+	}
+	for _, tt := range tests {
+		provider, ref, isSlug, ok := parseShowURL(tt.raw)
+		if ok != tt.ok || ok && (provider != tt.provider || ref != tt.ref ||
+			isSlug != tt.isSlug) {
+			t.Errorf("parseShowURL(%q) = %q, %q, %t, %t, want %q, %q, %t, %t",
+				tt.raw, provider, ref, isSlug, ok, tt.provider, tt.ref, tt.isSlug,
+				tt.ok)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package animecmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/inami-irc-bot/webutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+const settingsKey = "anime/settings"
+
+// AnimeProvider fetches show and episode data from an anime database.
+type AnimeProvider interface {
+	// Search returns up to five shows matching a query.
+	Search(query string) ([]Show, error)
+	// Show returns a show by its id, or a show with an empty id if it doesn't
+	// exist.
+	Show(id string) (Show, error)
+	// Episodes returns the known episodes of a show by its id.
+	Episodes(id string) ([]Episode, error)
+}
+
+// slugProvider is implemented by providers that can look up shows by the name
+// in their URL, such as Kitsu.
+type slugProvider interface {
+	// Slug returns a show by the name in its URL, or a show with an empty id if
+	// it doesn't exist.
+	Slug(slug string) (Show, error)
+}
+
+// Show stores show data returned by a provider. Length is how long each
+// episode runs for, and Episodes is how many episodes it has in total, or zero
+// if the provider doesn't know.
+type Show struct {
+	ID       string        `json:"id"`
+	Title    string        `json:"title"`
+	URL      string        `json:"url"`
+	Airing   bool          `json:"airing"`
+	Length   time.Duration `json:"length"`
+	Episodes int           `json:"episodes"`
+}
+
+// Episode stores episode data returned by a provider.
+type Episode struct {
+	Number int       `json:"number"`
+	Title  string    `json:"title"`
+	Aired  time.Time `json:"aired"`
+}
+
+// providers maps provider names to their implementations. It's filled in by
+// setProviders.
+var providers = map[string]AnimeProvider{}
+
+// defaultURLs maps provider names to the base URLs of their public APIs.
+var defaultURLs = map[string]string{
+	"kitsu":   "https://kitsu.io/api/edge",
+	"anilist": "https://graphql.anilist.co",
+	"mal":     "https://api.jikan.moe/v4",
+}
+
+// setProviders creates every provider with an API client and cache using
+// configured settings, and sets the default provider.
+func setProviders(config configutil.Anime) {
+	api := func(name string) *webutil.Client {
+		base := config.URLs[name]
+		if len(base) < 1 {
+			base = defaultURLs[name]
+		}
+		return webutil.NewClient(base, config.UserAgent,
+			time.Duration(config.Timeout*float64(time.Second)), config.Retries)
+	}
+	providers = map[string]AnimeProvider{
+		"kitsu":   kitsu{api: api("kitsu")},
+		"anilist": anilist{api: api("anilist")},
+		"mal":     jikan{api: api("mal")},
+	}
+
+	// Keep responses from every provider in the shared cache.
+	for name, p := range providers {
+		providers[name] = cached{name: name, provider: p, ttl: config.TTL}
+	}
+	if _, ok := providers[config.Provider]; ok {
+		defaultProvider = config.Provider
+	}
+}
+
+// defaultProvider is the provider used when a channel hasn't chosen one.
+var defaultProvider = "kitsu"
+
+// providerNames returns the names of all providers in alphabetical order.
+func providerNames() []string {
+	var names []string
+	for n := range providers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getProvider returns the name of the provider chosen for a message's scope,
+// or the default provider if none was chosen.
+func getProvider(client *ircutil.Client, message *ircutil.Message) string {
+	keys := []string{"", "", settingsKey, "provider"}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+	name, _ := configutil.GetValue(client, keys)
+	if _, ok := providers[name]; !ok {
+		return defaultProvider
+	}
+	return name
+}
+
+// formatShowID joins a provider name and show id for storing in an alias.
+func formatShowID(provider, id string) string {
+	return fmt.Sprintf("%s:%s", provider, id)
+}
+
+// parseShowID splits a show id into its provider name and provider-specific
+// id. Ids without a known provider prefix belong to the fallback provider,
+// which lets aliases saved before providers existed keep using Kitsu.
+func parseShowID(value, fallback string) (string, string) {
+	if i := strings.Index(value, ":"); i >= 0 {
+		if _, ok := providers[value[:i]]; ok {
+			return value[:i], value[i+1:]
+		}
+	}
+	return fallback, value
+}
+
+// sendFetchError logs an error from a provider and sends a response explaining
+// it, where what names the data that couldn't be fetched.
+func sendFetchError(client *ircutil.Client, message *ircutil.Message,
+	what string, err error) {
+	ircutil.Log(client, err.Error())
+
+	// Pick a response based on the type of error.
+	text := fmt.Sprintf("Error fetching %s, try again later", what)
+	var status *webutil.StatusError
+	var timeout *webutil.TimeoutError
+	var decode *webutil.DecodeError
+	switch {
+	case errors.As(err, &timeout):
+		text = "The anime database took too long to respond, try again later"
+	case errors.As(err, &status) &&
+		status.Code == http.StatusTooManyRequests:
+		text = "The anime database is busy, try again in a few minutes"
+	case errors.As(err, &status) && status.Code >= 500:
+		text = "The anime database is having problems, try again later"
+	case errors.As(err, &decode):
+		text = "The anime database sent something unexpected, try again later"
+	}
+	msgutil.SendResponse(client, message.Source, message.Target, text)
+}
@@ -0,0 +1,32 @@
+package animecmd
+
+import (
+	"testing"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+)
+
+func TestParseShowID(t *testing.T) {
+	setProviders(configutil.Anime{})
+	tests := []struct {
+		value    string
+		fallback string
+		provider string
+		id       string
+	}{
+		{"kitsu:1", "anilist", "kitsu", "1"},
+		{"anilist:21", "kitsu", "anilist", "21"},
+		{"mal:52991", "kitsu", "mal", "52991"},
+		{"7442", "kitsu", "kitsu", "7442"},
+		{"7442", "mal", "mal", "7442"},
+		{"unknown:5", "kitsu", "kitsu", "unknown:5"},
+		{"", "kitsu", "kitsu", ""},
+	}
+	for _, tt := range tests {
+		provider, id := parseShowID(tt.value, tt.fallback)
+		if provider != tt.provider || id != tt.id {
+			t.Errorf("parseShowID(%q, %q) = %q, %q, want %q, %q", tt.value,
+				tt.fallback, provider, id, tt.provider, tt.id)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package cacheutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+)
+
+// Cache stores values for a limited time. Caches are shared between clients,
+// so they must be safe to use from multiple goroutines.
+type Cache interface {
+	// Get returns a value, or false if it's missing or expired.
+	Get(key string) ([]byte, bool)
+	// Set stores a value for a duration, or without expiring if it's zero.
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// Default is the cache used by modules. It's replaced with the configured
+// cache on startup. Default: Memory cache with 1000 entries
+var Default Cache = NewMemory(1000)
+
+// backends maps backend names to functions that connect to a backend server
+// at an address. Backends add themselves so they can be left out of builds.
+var backends = map[string]func(address string) (Cache, error){}
+
+// New creates a cache using configured settings.
+func New(config configutil.Cache) (Cache, error) {
+	if len(config.Backend) < 1 || config.Backend == "memory" {
+		return NewMemory(config.Size), nil
+	}
+	open, ok := backends[config.Backend]
+	if !ok {
+		return nil, fmt.Errorf("creating cache: backend %s not available",
+			config.Backend)
+	}
+	if len(config.Address) < 1 {
+		return nil, fmt.Errorf("creating cache: backend %s needs an address",
+			config.Backend)
+	}
+	return open(config.Address)
+}
+
+// GetJSON gets a value from a cache and parses it into v. It returns false if
+// the value is missing or can't be parsed.
+func GetJSON(cache Cache, key string, v interface{}) bool {
+	raw, ok := cache.Get(key)
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(raw, v) == nil
+}
+
+// SetJSON encodes a value as JSON and stores it in a cache.
+func SetJSON(cache Cache, key string, v interface{}, ttl time.Duration) {
+	raw, err := json.Marshal(v)
+	if err == nil {
+		cache.Set(key, raw, ttl)
+	}
+}
+
+// Seconds converts a number of seconds from a config to a duration.
+func Seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
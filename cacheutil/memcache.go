@@ -0,0 +1,50 @@
+//go:build !nomemcache
+
+package cacheutil
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+func init() {
+	backends["memcached"] = func(address string) (Cache, error) {
+		return &memcacheCache{client: memcache.New(address)}, nil
+	}
+}
+
+// memcacheCache is a cache stored on a memcached server. Build with
+// -tags nomemcache to leave it out.
+type memcacheCache struct {
+	client *memcache.Client
+}
+
+// memcacheKey hashes a key, since memcached keys are limited in length and
+// can't contain spaces.
+func memcacheKey(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns a value, or false if it's missing, expired, or the server can't
+// be reached.
+func (c *memcacheCache) Get(key string) ([]byte, bool) {
+	it, err := c.client.Get(memcacheKey(key))
+	if err != nil {
+		return nil, false
+	}
+	return it.Value, true
+}
+
+// Set stores a value for a duration, or without expiring if it's zero. Values
+// are dropped if the server can't be reached.
+func (c *memcacheCache) Set(key string, value []byte, ttl time.Duration) {
+	c.client.Set(&memcache.Item{
+		Key:        memcacheKey(key),
+		Value:      value,
+		Expiration: int32((ttl + time.Second - 1) / time.Second),
+	})
+}
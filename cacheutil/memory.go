@@ -0,0 +1,85 @@
+package cacheutil
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+)
+
+// Memory is a cache kept in memory that removes the least recently used
+// values when it's full.
+type Memory struct {
+	size    int
+	mutex   sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// entry stores a value in a memory cache.
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory creates a memory cache holding up to size values, or any number of
+// values if size is less than one.
+func NewMemory(size int) *Memory {
+	return &Memory{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Get returns a value, or false if it's missing or expired.
+func (m *Memory) Get(key string) ([]byte, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Find entry, and remove it if it has expired.
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !e.expires.IsZero() && !clockutil.Now().Before(e.expires) {
+		m.order.Remove(el)
+		delete(m.entries, key)
+		return nil, false
+	}
+
+	// Mark entry as recently used and return its value.
+	m.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores a value for a duration, or without expiring if it's zero.
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Determine expiry time.
+	var expires time.Time
+	if ttl > 0 {
+		expires = clockutil.Now().Add(ttl)
+	}
+
+	// Update existing entry, or add a new one.
+	if el, ok := m.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expires = value, expires
+		m.order.MoveToFront(el)
+		return
+	}
+	m.entries[key] = m.order.PushFront(&entry{key, value, expires})
+
+	// Remove least recently used entries if the cache is full.
+	for m.size > 0 && m.order.Len() > m.size {
+		el := m.order.Back()
+		m.order.Remove(el)
+		delete(m.entries, el.Value.(*entry).key)
+	}
+}
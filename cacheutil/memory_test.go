@@ -0,0 +1,79 @@
+package cacheutil
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+)
+
+func TestMemory(t *testing.T) {
+	clock := clockutil.NewFake(time.Date(2017, time.January, 1, 0, 0, 0, 0,
+		time.UTC))
+	defer clockutil.Set(clock)()
+
+	// Each step sets or gets a key, or advances the clock, on a cache holding
+	// up to three values.
+	m := NewMemory(3)
+	tests := []struct {
+		op    string
+		key   string
+		ttl   time.Duration
+		want  string
+		found bool
+	}{
+		{"set", "a", 0, "a", false},
+		{"set", "b", time.Minute, "b", false},
+		{"set", "c", 0, "c", false},
+		{"get", "a", 0, "a", true},
+		{"get", "b", 0, "b", true},
+
+		// Adding a fourth value evicts the least recently used one.
+		{"set", "d", 0, "d", false},
+		{"get", "c", 0, "", false},
+		{"get", "a", 0, "a", true},
+		{"get", "d", 0, "d", true},
+
+		// Values expire after their time to live, at the exact moment.
+		{"advance", "", 59 * time.Second, "", false},
+		{"get", "b", 0, "b", true},
+		{"advance", "", time.Second, "", false},
+		{"get", "b", 0, "", false},
+		{"get", "a", 0, "a", true},
+
+		// Updating a value keeps its place and resets its expiry.
+		{"set", "a", time.Minute, "a2", false},
+		{"set", "e", 0, "e", false},
+		{"set", "f", 0, "f", false},
+		{"get", "d", 0, "", false},
+		{"get", "a", 0, "a2", true},
+		{"advance", "", time.Minute, "", false},
+		{"get", "a", 0, "", false},
+		{"get", "e", 0, "e", true},
+	}
+	for i, tt := range tests {
+		switch tt.op {
+		case "set":
+			m.Set(tt.key, []byte(tt.want), tt.ttl)
+		case "advance":
+			clock.Advance(tt.ttl)
+		case "get":
+			got, ok := m.Get(tt.key)
+			if ok != tt.found || string(got) != tt.want {
+				t.Errorf("step %d: Get(%q) = %q, %t, want %q, %t", i, tt.key, got,
+					ok, tt.want, tt.found)
+			}
+		}
+	}
+}
+
+func TestMemoryUnlimited(t *testing.T) {
+	m := NewMemory(0)
+	for i := 0; i < 2000; i++ {
+		m.Set(strconv.Itoa(i), []byte{1}, 0)
+	}
+	if n := m.order.Len(); n != 2000 {
+		t.Errorf("unlimited cache holds %d values, want 2000", n)
+	}
+}
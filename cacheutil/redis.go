@@ -0,0 +1,54 @@
+//go:build !noredis
+
+package cacheutil
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func init() {
+	backends["redis"] = func(address string) (Cache, error) {
+		return &redisCache{pool: &redis.Pool{
+			MaxIdle:     4,
+			IdleTimeout: 5 * time.Minute,
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", address,
+					redis.DialConnectTimeout(5*time.Second),
+					redis.DialReadTimeout(5*time.Second),
+					redis.DialWriteTimeout(5*time.Second))
+			},
+		}}, nil
+	}
+}
+
+// redisCache is a cache stored on a Redis server. Build with -tags noredis to
+// leave it out.
+type redisCache struct {
+	pool *redis.Pool
+}
+
+// Get returns a value, or false if it's missing, expired, or the server can't
+// be reached.
+func (c *redisCache) Get(key string) ([]byte, bool) {
+	conn := c.pool.Get()
+	defer conn.Close()
+	value, err := redis.Bytes(conn.Do("GET", key))
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Set stores a value for a duration, or without expiring if it's zero. Values
+// are dropped if the server can't be reached.
+func (c *redisCache) Set(key string, value []byte, ttl time.Duration) {
+	conn := c.pool.Get()
+	defer conn.Close()
+	if ttl > 0 {
+		conn.Do("SET", key, value, "PX", int64(ttl/time.Millisecond))
+		return
+	}
+	conn.Do("SET", key, value)
+}
@@ -6,13 +6,16 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/jasonpuglisi/inami-irc-bot/animecmd"
+	"github.com/jasonpuglisi/inami-irc-bot/cacheutil"
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
 	"github.com/jasonpuglisi/inami-irc-bot/configutil"
-	"github.com/jasonpuglisi/inami-irc-bot/funcmd"
-	"github.com/jasonpuglisi/inami-irc-bot/utilcmd"
+	"github.com/jasonpuglisi/inami-irc-bot/modutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
 	"github.com/jasonpuglisi/ircutil"
 )
 
@@ -51,15 +54,29 @@ func main() {
 		return
 	}
 
+	// Set response splitting limits.
+	msgutil.MaxLines = config.MaxLines
+
+	// Set up cache for responses from web APIs.
+	cache, err := cacheutil.New(config.Cache)
+	if err != nil {
+		fmt.Printf("Error setting up %s cache, make sure it's valid in %s.\n%s\n",
+			config.Cache.Backend, *configPtr, err)
+		return
+	}
+	cacheutil.Default = cache
+
 	// Seed random number generator.
 	rand.Seed(time.Now().UnixNano())
 
-	// Initialize and import commands.
+	// Initialize commands from every registered module. Modules are included
+	// by the imports in modules.go.
 	commands := config.Commands
 	cmdMap := ircutil.InitCommands()
-	utilcmd.Init(cmdMap)
-	funcmd.Init(cmdMap)
-	animecmd.Init(cmdMap)
+	modutil.InitAll(cmdMap, config)
+	cmdutil.NotifyAdmins = config.NotifyAdmins
+	cmdutil.Roles = config.Roles
+	cmdutil.Wrap(cmdMap)
 
 	// Declare slice to store clients.
 	var clients []*ircutil.Client
@@ -93,6 +110,8 @@ func main() {
 		client.CmdMap = cmdMap
 		client.Debug = *debugPtr
 		client.Ready = Init
+		client.Receive = cmdutil.Receive
+		client.Caps = cmdutil.Caps
 		client.Done = make(chan bool, 1)
 		client.Nick = client.User.Nick
 
@@ -114,10 +133,23 @@ func main() {
 		}
 	}
 
+	// Stop long-running commands and exit when interrupted.
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		cmdutil.Shutdown()
+
+		// Sleep for a second so stopped commands can send their responses.
+		time.Sleep(time.Second)
+		os.Exit(0)
+	}()
+
 	// Loop until all clients are no longer active.
 	for _, c := range clients {
 		<-c.Done
 	}
+	cmdutil.Shutdown()
 }
 
 // Init is executed after the client it connected and registered to the server.
@@ -128,6 +160,9 @@ func Init(client *ircutil.Client) {
 		ircutil.SendNickservPass(client, client.Authentication.Nickserv)
 	}
 
+	// Let modules set up the client.
+	modutil.ReadyAll(client)
+
 	// Set user modes if specified.
 	if len(client.Modes) > 0 {
 		ircutil.SendModeUser(client, client.Modes)
@@ -0,0 +1,140 @@
+package clockutil
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers. Commands use it instead of the time
+// package so tests can control time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives the time once a duration passes.
+	After(d time.Duration) <-chan time.Time
+}
+
+// clock is the clock used by Now and After.
+var clock Clock = Real{}
+
+// clockMu guards clock.
+var clockMu sync.RWMutex
+
+// Now returns the current time from the active clock.
+func Now() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock.Now()
+}
+
+// After waits for a duration on the active clock.
+func After(d time.Duration) <-chan time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock.After(d)
+}
+
+// Set replaces the active clock, and returns a function that restores the
+// previous one.
+func Set(c Clock) func() {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	old := clock
+	clock = c
+	return func() {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		clock = old
+	}
+}
+
+// Real is a clock that uses the time package.
+type Real struct{}
+
+// Now returns the current time.
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// After waits for a duration to pass.
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Fake is a clock that only moves when advanced, for tests.
+type Fake struct {
+	// mu guards now and timers.
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+// fakeTimer stores a pending call to After on a fake clock.
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFake creates a fake clock set to a time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the fake clock's time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel that receives the time once the fake clock has been
+// advanced by a duration.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+	f.timers = append(f.timers, fakeTimer{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the fake clock forward, firing timers that come due in order.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	sort.SliceStable(f.timers, func(i, j int) bool {
+		return f.timers[i].at.Before(f.timers[j].at)
+	})
+	for len(f.timers) > 0 && !f.timers[0].at.After(f.now) {
+		f.timers[0].ch <- f.timers[0].at
+		f.timers = f.timers[1:]
+	}
+}
+
+// Waiters returns the number of timers waiting on the fake clock, so tests can
+// wait for a command to reach a timer before advancing.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// Due returns the number of timers that would fire if the fake clock were
+// advanced by a duration, so tests can ignore timers of background tasks that
+// aren't due yet.
+func (f *Fake) Due(d time.Duration) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, t := range f.timers {
+		if !t.at.After(f.now.Add(d)) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,109 @@
+package cmdutil
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// cooldowns maps cooldown keys to the time a command can be used again.
+var cooldowns = map[string]time.Time{}
+
+// cooldownsMu guards cooldowns and lastPrune.
+var cooldownsMu sync.Mutex
+
+// lastPrune is when expired cooldowns were last removed.
+var lastPrune time.Time
+
+// pruneInterval is how often expired cooldowns are removed.
+const pruneInterval = time.Minute
+
+// checkCooldown makes sure a command isn't used again before its cooldowns
+// have passed, and sends a response if it is unless configured to be silent.
+// Users with the admin role are exempt.
+func checkCooldown(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) bool {
+	// Skip commands without cooldowns and admins.
+	cd := configutil.GetCommandInfo(command).Settings.Cooldown
+	if cd.User <= 0 && cd.Channel <= 0 && cd.Global <= 0 {
+		return true
+	}
+	if HasRole(client, message, "admin") {
+		return true
+	}
+
+	// Build a key for each scope with a cooldown.
+	prefix := ircutil.GetClientPrefix(client) + " " + command.Function
+	keys := map[string]float64{}
+	if cd.User > 0 {
+		keys[prefix+" user "+userKey(client, message.Source)] = cd.User
+	}
+	if cd.Channel > 0 && ircutil.IsChannel(message.Target) {
+		keys[prefix+" channel "+message.Target] = cd.Channel
+	}
+	if cd.Global > 0 {
+		keys[prefix+" global"] = cd.Global
+	}
+
+	cooldownsMu.Lock()
+	defer cooldownsMu.Unlock()
+	now := clockutil.Now()
+	pruneCooldowns(now)
+
+	// Find the longest remaining wait across all scopes.
+	var wait time.Duration
+	for key := range keys {
+		if left := cooldowns[key].Sub(now); left > wait {
+			wait = left
+		}
+	}
+
+	// Send response if the command is still cooling down.
+	if wait > 0 {
+		if !cd.Silent {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("Slow down, try again in %.0fs",
+					math.Ceil(wait.Seconds())))
+		}
+		return false
+	}
+
+	// Record when the command can be used again in every scope.
+	for key, seconds := range keys {
+		cooldowns[key] = now.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	return true
+}
+
+// userKey identifies a message source across nickname changes, by services
+// account if it's known, or by username and host.
+func userKey(client *ircutil.Client, source string) string {
+	if account := GetAccount(client, ircutil.GetNick(source)); len(account) > 0 {
+		return "$a:" + strings.ToLower(account)
+	}
+	if i := strings.IndexByte(source, '!'); i >= 0 {
+		return strings.ToLower(source[i+1:])
+	}
+	return strings.ToLower(source)
+}
+
+// pruneCooldowns removes cooldowns that have passed, at most once per prune
+// interval. It must be called with cooldownsMu held.
+func pruneCooldowns(now time.Time) {
+	if now.Sub(lastPrune) < pruneInterval {
+		return
+	}
+	lastPrune = now
+	for key, until := range cooldowns {
+		if !until.After(now) {
+			delete(cooldowns, key)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cmdutil
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// ignoresGroup is the data group ignored hostmask patterns are stored in.
+const ignoresGroup = "utility/ignores"
+
+// IsIgnored checks whether a message source matches an ignored hostmask
+// pattern, either globally or in the message's channel. Expired patterns are
+// removed as they are found.
+func IsIgnored(client *ircutil.Client, message *ircutil.Message) bool {
+	if HasRole(client, message, "admin") {
+		return false
+	}
+	channels := []string{""}
+	if ircutil.IsChannel(message.Target) {
+		channels = append(channels, message.Target)
+	}
+	for _, c := range channels {
+		for pattern := range GetIgnores(client, c) {
+			if MatchMask(pattern, message.Source) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetIgnores returns ignored hostmask patterns and their expiry times in a
+// channel, or globally if the channel is empty. Patterns that never expire
+// have a zero time.
+func GetIgnores(client *ircutil.Client, channel string) map[string]time.Time {
+	keys := ignoreKeys(channel, "")
+	values, err := configutil.GetGroup(client, keys[:3])
+	if err != nil {
+		return nil
+	}
+
+	// Parse expiry times, removing patterns that have expired.
+	ignores := map[string]time.Time{}
+	for pattern, v := range values {
+		expiry := time.Time{}
+		if unix, _ := strconv.ParseInt(v, 10, 64); unix > 0 {
+			expiry = time.Unix(unix, 0)
+			if clockutil.Now().After(expiry) {
+				Unignore(client, channel, pattern)
+				continue
+			}
+		}
+		ignores[pattern] = expiry
+	}
+	return ignores
+}
+
+// Ignore saves a hostmask pattern to ignore in a channel, or globally if the
+// channel is empty. A zero duration never expires.
+func Ignore(client *ircutil.Client, channel string, pattern string,
+	duration time.Duration) error {
+	expiry := "0"
+	if duration > 0 {
+		expiry = strconv.FormatInt(clockutil.Now().Add(duration).Unix(), 10)
+	}
+	return configutil.SetValue(client, ignoreKeys(channel, pattern), expiry)
+}
+
+// Unignore removes an ignored hostmask pattern in a channel, or globally if the
+// channel is empty.
+func Unignore(client *ircutil.Client, channel string, pattern string) error {
+	return configutil.DeleteValue(client, ignoreKeys(channel, pattern))
+}
+
+// NormalizeMask turns a bare nickname into a hostmask pattern matching it, and
+// leaves other patterns alone.
+func NormalizeMask(pattern string) string {
+	if !strings.ContainsAny(pattern, "!@") {
+		return pattern + "!*@*"
+	}
+	return pattern
+}
+
+// ignoreKeys returns the data keys for an ignored pattern in a channel, or
+// globally if the channel is empty.
+func ignoreKeys(channel string, pattern string) []string {
+	if len(channel) > 0 {
+		return []string{"channel", channel, ignoresGroup, strings.ToLower(pattern)}
+	}
+	return []string{"client", "global", ignoresGroup, strings.ToLower(pattern)}
+}
@@ -0,0 +1,46 @@
+package cmdutil
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+func TestReceiveIgnores(t *testing.T) {
+	defer func(roles map[string][]string) { Roles = roles }(Roles)
+	Roles = map[string][]string{"admin": {"alice!*@*"}}
+	file := filepath.Join(t.TempDir(), "data.json")
+	client := &ircutil.Client{ServerID: "test", UserID: "ignore", Nick: "bot",
+		Data: ircutil.Data{}, DataFile: &file}
+	if err := Ignore(client, "", NormalizeMask("bob"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Ignore(client, "#chan", "*!*@spam.host", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := Ignore(client, "", "alice!*@*", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{":bob!b@host PRIVMSG #chan :!help", false},
+		{":bob!b@host NOTICE bot :hi", false},
+		{":bob!b@host JOIN #chan", true},
+		{":carol!c@spam.host PRIVMSG #chan :!help", false},
+		{":carol!c@spam.host PRIVMSG #other :!help", true},
+		{":carol!c@spam.host PRIVMSG bot :help", true},
+		{":alice!a@host PRIVMSG #chan :!help", true},
+		{":irc.server NOTICE * :Looking up your hostname", true},
+		{"PING :irc.server", true},
+	}
+	for _, tt := range tests {
+		if got := Receive(client, tt.line); got != tt.want {
+			t.Errorf("Receive(%q) = %t, want %t", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package cmdutil
+
+import (
+	"github.com/jasonpuglisi/inami-irc-bot/eventutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Receive keeps channel statuses and services accounts up to date for role
+// checks from every line a client receives. Messages and notices from ignored
+// sources are dropped here, before any command is matched or event published,
+// and every other line is published to the event bus. It should be set as each
+// client's receive function.
+func Receive(client *ircutil.Client, raw string) bool {
+	// Parse the line, leaving anything unexpected to the client.
+	e, err := eventutil.Parse(raw)
+	if err != nil {
+		return true
+	}
+	e.Client = client
+
+	// Track the line, and drop it if it's from an ignored source.
+	track(client, e)
+	if (e.Type == "PRIVMSG" || e.Type == "NOTICE") && len(e.Nick) > 0 &&
+		IsIgnored(client, &ircutil.Message{Source: e.Source,
+			Target: e.Param(0)}) {
+		return false
+	}
+	eventutil.Publish(e)
+	return true
+}
@@ -0,0 +1,207 @@
+package cmdutil
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// rolesGroup is the data group role grants are stored in.
+const rolesGroup = "utility/roles"
+
+// RoleNames lists valid roles from least to most privileged.
+var RoleNames = []string{"trusted", "moderator", "admin", "owner"}
+
+// Roles maps role names to grants from the config file. A grant is a hostmask
+// pattern such as "*!*@example.com", or "$a:" followed by a services account
+// name.
+var Roles map[string][]string
+
+// RoleLevel returns the privilege level of a role, or 0 if it isn't valid.
+func RoleLevel(role string) int {
+	for i, r := range RoleNames {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// HasRole checks whether a message source has at least the given role in the
+// message's channel. Roles can come from config grants, grants saved with
+// GrantRole, or channel status. An empty role is always held, and an invalid
+// one never is.
+func HasRole(client *ircutil.Client, message *ircutil.Message,
+	role string) bool {
+	if len(role) < 1 {
+		return true
+	}
+	need := RoleLevel(role)
+	if need == 0 {
+		return false
+	}
+	return userLevel(client, message.Source, message.Target) >= need
+}
+
+// userLevel returns the highest privilege level of a source in a target.
+func userLevel(client *ircutil.Client, source string, target string) int {
+	nick := ircutil.GetNick(source)
+	account := GetAccount(client, nick)
+	status := GetStatus(client, target, nick)
+
+	// Check config, global, and channel grants.
+	level := 0
+	for _, role := range RoleNames {
+		for _, g := range allGrants(client, target, role) {
+			if matchGrant(g, source, account) && RoleLevel(role) > level {
+				level = RoleLevel(role)
+			}
+		}
+	}
+
+	// Check channel status, where operators and half-operators moderate and
+	// voiced users are trusted.
+	switch {
+	case strings.ContainsAny(status, "~&@%"):
+		if l := RoleLevel("moderator"); l > level {
+			level = l
+		}
+	case strings.Contains(status, "+"):
+		if l := RoleLevel("trusted"); l > level {
+			level = l
+		}
+	}
+	return level
+}
+
+// adminNicks returns the nicknames logged in to services accounts with the
+// admin role or higher from config or global grants. Hostmask grants aren't
+// used, since the nickname in one could belong to someone else by now.
+func adminNicks(client *ircutil.Client) []string {
+	var nicks []string
+	for nick, account := range getAccounts(client) {
+		if accountLevel(client, account) >= RoleLevel("admin") {
+			nicks = append(nicks, nick)
+		}
+	}
+	sort.Strings(nicks)
+	return nicks
+}
+
+// accountLevel returns the highest privilege level of a services account from
+// config or global grants.
+func accountLevel(client *ircutil.Client, account string) int {
+	level := 0
+	for _, role := range RoleNames {
+		for _, g := range allGrants(client, "", role) {
+			if strings.HasPrefix(g, "$a:") && matchGrant(g, "", account) {
+				level = RoleLevel(role)
+			}
+		}
+	}
+	return level
+}
+
+// allGrants returns the config and global grants for a role, along with the
+// saved grants in a channel if one is given.
+func allGrants(client *ircutil.Client, channel string, role string) []string {
+	var grants []string
+	grants = append(grants, Roles[role]...)
+	grants = append(grants, GetGrants(client, "", role)...)
+	if ircutil.IsChannel(channel) {
+		grants = append(grants, GetGrants(client, channel, role)...)
+	}
+	return grants
+}
+
+// matchGrant checks whether a grant applies to a source with an account.
+func matchGrant(grant string, source string, account string) bool {
+	if strings.HasPrefix(grant, "$a:") {
+		return len(account) > 0 && strings.EqualFold(grant[3:], account)
+	}
+	return MatchMask(grant, source)
+}
+
+// MatchMask checks whether a hostmask matches a pattern containing "*" and "?"
+// wildcards, ignoring case.
+func MatchMask(pattern string, mask string) bool {
+	p, m := strings.ToLower(pattern), strings.ToLower(mask)
+
+	// Match greedily, backtracking to the last star on a mismatch.
+	pi, mi, star, next := 0, 0, -1, 0
+	for mi < len(m) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == m[mi]):
+			pi++
+			mi++
+		case pi < len(p) && p[pi] == '*':
+			star, next = pi, mi
+			pi++
+		case star >= 0:
+			pi, next = star+1, next+1
+			mi = next
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+// GetGrants returns the saved grants for a role in a channel, or globally if
+// the channel is empty.
+func GetGrants(client *ircutil.Client, channel string, role string) []string {
+	value, err := configutil.GetValue(client, grantKeys(channel, role))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(value)
+}
+
+// GrantRole saves a grant for a role in a channel, or globally if the channel
+// is empty.
+func GrantRole(client *ircutil.Client, channel string, role string,
+	grant string) error {
+	grants := GetGrants(client, channel, role)
+	for _, g := range grants {
+		if strings.EqualFold(g, grant) {
+			return nil
+		}
+	}
+	grants = append(grants, grant)
+	return configutil.SetValue(client, grantKeys(channel, role),
+		strings.Join(grants, " "))
+}
+
+// RevokeRole removes a saved grant for a role in a channel, or globally if the
+// channel is empty. It returns false if the grant wasn't found.
+func RevokeRole(client *ircutil.Client, channel string, role string,
+	grant string) (bool, error) {
+	var kept []string
+	found := false
+	for _, g := range GetGrants(client, channel, role) {
+		if strings.EqualFold(g, grant) {
+			found = true
+		} else {
+			kept = append(kept, g)
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	return true, configutil.SetValue(client, grantKeys(channel, role),
+		strings.Join(kept, " "))
+}
+
+// grantKeys returns the data keys for grants of a role in a channel, or
+// globally if the channel is empty.
+func grantKeys(channel string, role string) []string {
+	if len(channel) > 0 {
+		return []string{"channel", channel, rolesGroup, role}
+	}
+	return []string{"client", "global", rolesGroup, role}
+}
@@ -0,0 +1,94 @@
+package cmdutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+func TestMatchMask(t *testing.T) {
+	tests := []struct {
+		pattern string
+		mask    string
+		want    bool
+	}{
+		{"*", "", true},
+		{"*", "nick!user@host", true},
+		{"*!*@*", "nick!user@host", true},
+		{"nick!user@host", "nick!user@host", true},
+		{"NICK!*@*", "nick!user@host", true},
+		{"nick!*@*", "nick2!user@host", false},
+		{"*!*@*.example.com", "a!b@irc.example.com", true},
+		{"*!*@*.example.com", "a!b@example.com", false},
+		{"n?ck!*@*", "nick!user@host", true},
+		{"n?ck!*@*", "nck!user@host", false},
+		{"*a*b*c", "xaxbxc", true},
+		{"*a*b*c", "xaxbxcx", false},
+		{"a**b", "ab", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		if got := MatchMask(tt.pattern, tt.mask); got != tt.want {
+			t.Errorf("MatchMask(%q, %q) = %t, want %t", tt.pattern, tt.mask, got,
+				tt.want)
+		}
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	defer func(roles map[string][]string) { Roles = roles }(Roles)
+	Roles = map[string][]string{
+		"owner":   {"alice!*@alice.host"},
+		"admin":   {"$a:CarolAcct"},
+		"trusted": {"*!*@trusted.host"},
+	}
+	client := &ircutil.Client{ServerID: "test", UserID: "role", Nick: "bot",
+		Data: ircutil.Data{}}
+
+	// Track accounts and statuses from the lines the server sends.
+	for _, l := range []string{
+		":irc 353 bot = #chan :@dave +erin frank",
+		":carol!c@elsewhere JOIN #chan CarolAcct :Carol",
+		":mallory!m@elsewhere JOIN #chan * :Mallory",
+		":irc MODE #chan +o-o+v frank dave dave",
+	} {
+		Receive(client, l)
+	}
+
+	tests := []struct {
+		source string
+		target string
+		role   string
+		want   bool
+	}{
+		{"alice!a@alice.host", "#chan", "owner", true},
+		{"alice!a@other.host", "#chan", "trusted", false},
+		{"carol!c@elsewhere", "", "admin", true},
+		{"carol!c@elsewhere", "", "owner", false},
+		{"mallory!m@elsewhere", "", "trusted", false},
+		{"bob!b@trusted.host", "", "trusted", true},
+		{"bob!b@trusted.host", "", "", true},
+		{"bob!b@trusted.host", "", "boss", false},
+		{"dave!d@host", "#chan", "trusted", true},
+		{"dave!d@host", "#chan", "moderator", false},
+		{"erin!e@host", "#chan", "trusted", true},
+		{"frank!f@host", "#chan", "moderator", true},
+		{"frank!f@host", "#other", "trusted", false},
+	}
+	for _, tt := range tests {
+		message := &ircutil.Message{Source: tt.source, Target: tt.target}
+		if got := HasRole(client, message, tt.role); got != tt.want {
+			t.Errorf("HasRole(%s in %q, %q) = %t, want %t", tt.source,
+				tt.target, tt.role, got, tt.want)
+		}
+	}
+
+	// Only admins granted by account are notified, under their new nickname
+	// after a change.
+	Receive(client, ":carol!c@elsewhere NICK :caroline")
+	if got := strings.Join(adminNicks(client), " "); got != "caroline" {
+		t.Errorf("adminNicks = %q, want %q", got, "caroline")
+	}
+}
@@ -0,0 +1,76 @@
+package cmdutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// root is the parent context of every session, cancelled when the bot shuts
+// down.
+var root, shutdown = context.WithCancel(context.Background())
+
+// sessions maps client prefixes and targets to the cancel function of their
+// active session.
+var sessions = map[string]context.CancelFunc{}
+
+// sessionsMu guards sessions.
+var sessionsMu sync.Mutex
+
+// Shutdown cancels every active session.
+func Shutdown() {
+	shutdown()
+}
+
+// Context returns a context that is cancelled when the bot shuts down, for
+// background tasks that aren't tied to a target.
+func Context() context.Context {
+	return root
+}
+
+// BeginSession starts a long-running session for a target, such as a
+// countdown in a channel. Only one session can be active per target. It
+// returns a context that is cancelled when the session is stopped or the bot
+// shuts down, and a function that must be called when the session ends. If a
+// session is already active, ok is false.
+func BeginSession(client *ircutil.Client, target string) (
+	ctx context.Context, end func(), ok bool) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	// Refuse to start a second session for the same target.
+	key := sessionKey(client, target)
+	if _, exists := sessions[key]; exists {
+		return nil, nil, false
+	}
+
+	// Store the session's cancel function, and remove it when the session
+	// ends.
+	ctx, cancel := context.WithCancel(root)
+	sessions[key] = cancel
+	end = func() {
+		sessionsMu.Lock()
+		defer sessionsMu.Unlock()
+		cancel()
+		delete(sessions, key)
+	}
+	return ctx, end, true
+}
+
+// CancelSession stops the active session for a target. It returns false if
+// there is no active session.
+func CancelSession(client *ircutil.Client, target string) bool {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	cancel, ok := sessions[sessionKey(client, target)]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// sessionKey builds a key identifying a target of a client.
+func sessionKey(client *ircutil.Client, target string) string {
+	return ircutil.GetClientPrefix(client) + " " + target
+}
@@ -0,0 +1,246 @@
+package cmdutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jasonpuglisi/inami-irc-bot/eventutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Caps lists the IRCv3 capabilities clients request so services accounts can
+// be tracked for role grants: accounts on join, account changes, and accounts
+// on every message.
+var Caps = []string{"extended-join", "account-notify", "account-tag"}
+
+// statusPrefixes maps channel prefix modes to the status prefixes they give,
+// from highest to lowest.
+var statusPrefixes = []struct{ mode, prefix byte }{
+	{'q', '~'}, {'a', '&'}, {'o', '@'}, {'h', '%'}, {'v', '+'},
+}
+
+// whoToken marks replies to the WHO queries sent to look up accounts.
+const whoToken = "31"
+
+// accounts maps client prefixes to nicknames and the services account names
+// they are logged in to.
+var accounts = map[string]map[string]string{}
+
+// statuses maps client prefixes and channels to nicknames and their channel
+// status prefixes, such as "@" or "+".
+var statuses = map[string]map[string]string{}
+
+// trackMu guards accounts and statuses.
+var trackMu sync.RWMutex
+
+// SetAccount records the services account a nickname is logged in to. An
+// empty account means the nickname is logged out.
+func SetAccount(client *ircutil.Client, nick string, account string) {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	setTracked(accounts, trackKey(client), nick, account)
+}
+
+// GetAccount returns the services account a nickname is logged in to, if
+// known.
+func GetAccount(client *ircutil.Client, nick string) string {
+	trackMu.RLock()
+	defer trackMu.RUnlock()
+	return accounts[trackKey(client)][strings.ToLower(nick)]
+}
+
+// getAccounts returns a copy of the nicknames on a client with a known
+// services account, mapped to the account.
+func getAccounts(client *ircutil.Client) map[string]string {
+	trackMu.RLock()
+	defer trackMu.RUnlock()
+	copied := map[string]string{}
+	for nick, account := range accounts[trackKey(client)] {
+		copied[nick] = account
+	}
+	return copied
+}
+
+// SetStatus records the channel status prefixes of a nickname in a channel. An
+// empty status means the nickname has no status.
+func SetStatus(client *ircutil.Client, channel string, nick string,
+	status string) {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	setTracked(statuses, trackKey(client, channel), nick, status)
+}
+
+// GetStatus returns the channel status prefixes of a nickname in a channel.
+func GetStatus(client *ircutil.Client, channel string, nick string) string {
+	trackMu.RLock()
+	defer trackMu.RUnlock()
+	return statuses[trackKey(client, channel)][strings.ToLower(nick)]
+}
+
+// RenameNick moves everything recorded about a nickname to a new one.
+func RenameNick(client *ircutil.Client, old string, new string) {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	prefix := trackKey(client)
+	for key, nicks := range statuses {
+		if strings.HasPrefix(key, prefix) {
+			renameTracked(nicks, old, new)
+		}
+	}
+	renameTracked(accounts[prefix], old, new)
+}
+
+// ForgetNick removes everything recorded about a nickname, such as when it
+// quits.
+func ForgetNick(client *ircutil.Client, nick string) {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	prefix := trackKey(client)
+	for key, nicks := range statuses {
+		if strings.HasPrefix(key, prefix) {
+			delete(nicks, strings.ToLower(nick))
+		}
+	}
+	delete(accounts[prefix], strings.ToLower(nick))
+}
+
+// ForgetChannel removes every status recorded in a channel, such as when the
+// client leaves it.
+func ForgetChannel(client *ircutil.Client, channel string) {
+	trackMu.Lock()
+	defer trackMu.Unlock()
+	delete(statuses, trackKey(client, channel))
+}
+
+// setTracked sets or, if the value is empty, removes a nickname's value in a
+// tracking map. It must be called with trackMu held.
+func setTracked(tracked map[string]map[string]string, key string, nick string,
+	value string) {
+	if len(value) < 1 {
+		delete(tracked[key], strings.ToLower(nick))
+		return
+	}
+	if tracked[key] == nil {
+		tracked[key] = map[string]string{}
+	}
+	tracked[key][strings.ToLower(nick)] = value
+}
+
+// renameTracked moves a nickname's value to a new nickname. It must be called
+// with trackMu held.
+func renameTracked(nicks map[string]string, old string, new string) {
+	if v, ok := nicks[strings.ToLower(old)]; ok {
+		delete(nicks, strings.ToLower(old))
+		nicks[strings.ToLower(new)] = v
+	}
+}
+
+// trackKey builds a key identifying a client, optionally followed by a
+// channel.
+func trackKey(client *ircutil.Client, channel ...string) string {
+	return ircutil.GetClientPrefix(client) + " " +
+		strings.ToLower(strings.Join(channel, " "))
+}
+
+// track updates channel statuses and services accounts from an event.
+func track(client *ircutil.Client, e *eventutil.Event) {
+	// Record accounts from message tags.
+	if account, ok := e.Tags["account"]; ok && len(e.Nick) > 0 {
+		SetAccount(client, e.Nick, account)
+	}
+
+	switch e.Type {
+	case "353":
+		// Record statuses from the names list sent on join.
+		for _, n := range strings.Fields(e.Param(3)) {
+			nick := strings.TrimLeft(n, "~&@%+")
+			SetStatus(client, e.Param(2), nick, n[:len(n)-len(nick)])
+		}
+	case "JOIN":
+		// Record accounts from extended joins, and look up the accounts of
+		// everyone already in a channel the client joins.
+		if len(e.Params) > 1 && e.Param(1) != "*" {
+			SetAccount(client, e.Nick, e.Param(1))
+		}
+		if e.Nick == client.Nick {
+			ircutil.SendRaw(client, fmt.Sprintf("WHO %s %%tna,%s", e.Param(0),
+				whoToken))
+		}
+	case "354":
+		if e.Param(1) == whoToken && len(e.Param(3)) > 0 && e.Param(3) != "0" {
+			SetAccount(client, e.Param(2), e.Param(3))
+		}
+	case "ACCOUNT":
+		account := e.Param(0)
+		if account == "*" {
+			account = ""
+		}
+		SetAccount(client, e.Nick, account)
+	case "PART":
+		// Clear statuses on leaving.
+		if e.Nick == client.Nick {
+			ForgetChannel(client, e.Param(0))
+		} else {
+			SetStatus(client, e.Param(0), e.Nick, "")
+		}
+	case "KICK":
+		if e.Param(1) == client.Nick {
+			ForgetChannel(client, e.Param(0))
+		} else {
+			SetStatus(client, e.Param(0), e.Param(1), "")
+		}
+	case "QUIT":
+		ForgetNick(client, e.Nick)
+	case "NICK":
+		RenameNick(client, e.Nick, e.Param(0))
+	case "MODE":
+		// Update statuses from channel mode changes.
+		if ircutil.IsChannel(e.Param(0)) && len(e.Params) > 1 {
+			applyModes(client, e.Param(0), e.Params[1], e.Params[2:])
+		}
+	}
+}
+
+// applyModes updates channel statuses from a mode string and its parameters.
+// Only prefix modes are tracked, but parameters of other modes are skipped
+// correctly for the common ones.
+func applyModes(client *ircutil.Client, channel string, modes string,
+	params []string) {
+	adding := true
+	for i := 0; i < len(modes); i++ {
+		m := modes[i]
+		switch {
+		case m == '+' || m == '-':
+			adding = m == '+'
+			continue
+		case strings.IndexByte("beIkfjL", m) >= 0 || m == 'l' && adding:
+			// Skip the parameter of a list or setting mode.
+			if len(params) > 0 {
+				params = params[1:]
+			}
+			continue
+		}
+
+		// Add or remove a status prefix, keeping them in order.
+		for _, sp := range statusPrefixes {
+			if sp.mode != m || len(params) < 1 {
+				continue
+			}
+			nick := params[0]
+			params = params[1:]
+			status := strings.Replace(GetStatus(client, channel, nick),
+				string(sp.prefix), "", -1)
+			if adding {
+				status += string(sp.prefix)
+			}
+			ordered := ""
+			for _, o := range statusPrefixes {
+				if strings.IndexByte(status, o.prefix) >= 0 {
+					ordered += string(o.prefix)
+				}
+			}
+			SetStatus(client, channel, nick, ordered)
+		}
+	}
+}
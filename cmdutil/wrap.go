@@ -0,0 +1,160 @@
+package cmdutil
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// NotifyAdmins determines whether admins and owners granted by services
+// account are sent a private message when a command function panics.
+// Default: false
+var NotifyAdmins bool
+
+// Wrap replaces every function in a command map with one that checks a
+// message before running the original function. It should be called after
+// all modules have added their functions.
+func Wrap(cmdMap ircutil.CmdMap) {
+	for key, fn := range cmdMap {
+		cmdMap[key] = wrap(fn)
+	}
+}
+
+// wrap returns a function that only runs a command function if its message
+// passes all checks.
+func wrap(fn func(*ircutil.Client, *ircutil.Command, *ircutil.Message)) func(
+	*ircutil.Client, *ircutil.Command, *ircutil.Message) {
+	return func(client *ircutil.Client, command *ircutil.Command,
+		message *ircutil.Message) {
+		defer recoverPanic(client, command, message)
+		if !checkRole(client, command, message) ||
+			!checkArgs(client, command, message) ||
+			!checkCooldown(client, command, message) {
+			return
+		}
+		fn(client, command, message)
+	}
+}
+
+// recoverPanic recovers from a panic in a command function so it doesn't take
+// down other clients. It logs the panic and lets the user and admins know.
+func recoverPanic(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	// Log panic with enough context to find the cause.
+	ircutil.Log(client, fmt.Sprintf("Panic in %s from %s in %s: %v\n%s",
+		command.Function, message.Source, message.Target, r, debug.Stack()))
+
+	// Send response with a generic error.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		"Something went wrong, try again later")
+
+	// Notify admins.
+	notifyAdmins(client, fmt.Sprintf("Command %s panicked for %s in %s: %v",
+		command.Function, ircutil.GetNick(message.Source), message.Target, r))
+}
+
+// Go runs a function in a new goroutine, recovering from a panic in it the
+// same way as in command functions. Command functions and event handlers use
+// it for anything they leave running, with a name describing it in logs.
+func Go(client *ircutil.Client, name string, fn func()) {
+	go func() {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			ircutil.Log(client, fmt.Sprintf("Panic in %s: %v\n%s", name, r,
+				debug.Stack()))
+			notifyAdmins(client, fmt.Sprintf("%s panicked: %v", name, r))
+		}()
+		fn()
+	}()
+}
+
+// notifyAdmins sends admins a private message about a panic if enabled.
+func notifyAdmins(client *ircutil.Client, text string) {
+	if !NotifyAdmins {
+		return
+	}
+	for _, nick := range adminNicks(client) {
+		msgutil.SendPrivmsg(client, nick, text)
+	}
+}
+
+// checkRole makes sure a message source has the role its command requires,
+// and sends a response if they don't.
+func checkRole(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) bool {
+	role := configutil.GetCommandInfo(command).Settings.Role
+	if !HasRole(client, message, role) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("You need to be %s to use that", role))
+		return false
+	}
+	return true
+}
+
+// Available checks whether a command can be used by a message source in the
+// message's scope.
+func Available(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) bool {
+	// Check role.
+	role := configutil.GetCommandInfo(command).Settings.Role
+	if !HasRole(client, message, role) {
+		return false
+	}
+
+	// Check scope.
+	scope := "direct"
+	if ircutil.IsChannel(message.Target) {
+		scope = "channel"
+	}
+	for _, s := range command.Settings.Scope {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// checkArgs makes sure a message's arguments match its command's arguments
+// string, and sends usage information if they don't.
+func checkArgs(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) bool {
+	_, err := configutil.GetCommandInfo(command).Spec.Bind(message.Args)
+	if err != nil {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Usage: %s", Usage(client, command)))
+		return false
+	}
+	return true
+}
+
+// Usage returns a command's primary trigger followed by its arguments string.
+func Usage(client *ircutil.Client, command *ircutil.Command) string {
+	if len(command.Triggers) < 1 {
+		return command.Arguments
+	}
+	usage := FormatTrigger(client, command, command.Triggers[0])
+	if len(command.Arguments) > 0 {
+		usage = fmt.Sprintf("%s %s", usage, command.Arguments)
+	}
+	return usage
+}
+
+// FormatTrigger prefixes a trigger with its command's symbol, and replaces
+// the nickname placeholder with the client's current nickname.
+func FormatTrigger(client *ircutil.Client, command *ircutil.Command,
+	trigger string) string {
+	return command.Settings.Symbol + strings.Replace(trigger, "%NICK%",
+		client.Nick, -1)
+}
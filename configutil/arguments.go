@@ -0,0 +1,100 @@
+package configutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Argument stores a single parsed argument from a command's arguments string.
+type Argument struct {
+	// Name of the argument, used to look up its value.
+	Name string
+	// Whether the argument must be present.
+	Required bool
+	// Whether the argument consumes all remaining words.
+	Variadic bool
+}
+
+// ArgSpec stores the parsed arguments string of a command, in the format
+// "<required> [optional] <variadic...>".
+type ArgSpec []Argument
+
+// Args maps argument names to their values. Variadic values are joined with
+// spaces.
+type Args map[string]string
+
+// ParseArgSpec parses a command's arguments string into an argument spec. It
+// returns an error if the string is malformed.
+func ParseArgSpec(spec string) (ArgSpec, error) {
+	var args ArgSpec
+	for _, f := range strings.Fields(spec) {
+		// Determine whether the argument is required from its brackets.
+		a := Argument{}
+		switch {
+		case strings.HasPrefix(f, "<") && strings.HasSuffix(f, ">"):
+			a.Required = true
+		case strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]"):
+		default:
+			return nil, fmt.Errorf("parsing arguments: invalid argument %s", f)
+		}
+		a.Name = f[1 : len(f)-1]
+		if strings.HasSuffix(a.Name, "...") {
+			a.Name, a.Variadic = strings.TrimSuffix(a.Name, "..."), true
+		}
+		if len(a.Name) < 1 {
+			return nil, fmt.Errorf("parsing arguments: unnamed argument %s", f)
+		}
+
+		// Make sure arguments are in an order that can be matched.
+		if len(args) > 0 {
+			last := args[len(args)-1]
+			if last.Variadic {
+				return nil, errors.New(
+					"parsing arguments: variadic argument must be last")
+			}
+			if a.Required && !last.Required {
+				return nil, errors.New(
+					"parsing arguments: required argument after optional")
+			}
+		}
+		args = append(args, a)
+	}
+	return args, nil
+}
+
+// Bind matches words from a message to an argument spec. It returns an error
+// if there are too few or too many words.
+func (spec ArgSpec) Bind(words []string) (Args, error) {
+	args := Args{}
+	for i, a := range spec {
+		if i >= len(words) {
+			if a.Required {
+				return nil, errors.New("binding arguments: missing " + a.Name)
+			}
+			break
+		}
+		if a.Variadic {
+			args[a.Name] = strings.Join(words[i:], " ")
+			return args, nil
+		}
+		args[a.Name] = words[i]
+	}
+	if len(words) > len(spec) {
+		return nil, errors.New("binding arguments: too many arguments")
+	}
+	return args, nil
+}
+
+// GetArgs returns the named arguments of a message for a command. Arguments
+// are checked before commands run, so missing optional arguments are simply
+// empty.
+func GetArgs(command *ircutil.Command, message *ircutil.Message) Args {
+	args, err := GetCommandInfo(command).Spec.Bind(message.Args)
+	if err != nil {
+		return Args{}
+	}
+	return args
+}
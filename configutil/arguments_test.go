@@ -0,0 +1,77 @@
+package configutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want ArgSpec
+		err  bool
+	}{
+		{"", nil, false},
+		{"<nick>", ArgSpec{{Name: "nick", Required: true}}, false},
+		{"<alias> [count]", ArgSpec{{Name: "alias", Required: true},
+			{Name: "count"}}, false},
+		{"<show...>", ArgSpec{{Name: "show", Required: true, Variadic: true}},
+			false},
+		{"[text...]", ArgSpec{{Name: "text", Variadic: true}}, false},
+		{"nick", nil, true},
+		{"<nick", nil, true},
+		{"<>", nil, true},
+		{"[...]", nil, true},
+		{"<words...> <nick>", nil, true},
+		{"[count] <alias>", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseArgSpec(tt.spec)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseArgSpec(%q) error = %v, want error %t", tt.spec, err,
+				tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseArgSpec(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		spec  string
+		words string
+		want  Args
+		err   bool
+	}{
+		{"", "", Args{}, false},
+		{"", "extra", nil, true},
+		{"<nick>", "alice", Args{"nick": "alice"}, false},
+		{"<nick>", "", nil, true},
+		{"<nick>", "alice bob", nil, true},
+		{"<alias> [count]", "foo", Args{"alias": "foo"}, false},
+		{"<alias> [count]", "foo 3", Args{"alias": "foo", "count": "3"}, false},
+		{"<alias> <show...>", "foo a b c", Args{"alias": "foo",
+			"show": "a b c"}, false},
+		{"<alias> <show...>", "foo", nil, true},
+		{"[text...]", "", Args{}, false},
+	}
+	for _, tt := range tests {
+		spec, err := ParseArgSpec(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseArgSpec(%q): %s", tt.spec, err)
+		}
+		got, err := spec.Bind(strings.Fields(tt.words))
+		if (err != nil) != tt.err {
+			t.Errorf("Bind(%q, %q) error = %v, want error %t", tt.spec, tt.words,
+				err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Bind(%q, %q) = %v, want %v", tt.spec, tt.words, got,
+				tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package configutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// CommandInfo stores command configuration values that are used by the bot's
+// modules rather than by ircutil.
+type CommandInfo struct {
+	// (Optional) Short explanation of what the command does, shown in help
+	// output. Default: Empty
+	Description string `json:"description"`
+	// (Optional) Settings used by the bot's modules. Default: All nested
+	// defaults
+	Settings CommandSettings `json:"settings"`
+	// (Optional) Regular expression that runs the command when it matches
+	// anywhere in a message, passing its capture groups as arguments. Can be
+	// used instead of or alongside triggers. Default: Empty
+	Pattern string `json:"pattern"`
+	// Parsed arguments string, used to check and name message arguments.
+	Spec ArgSpec `json:"-"`
+	// Compiled pattern, or nil if there isn't one.
+	Regexp *regexp.Regexp `json:"-"`
+}
+
+// CommandSettings stores command settings that are used by the bot's modules
+// rather than by ircutil.
+type CommandSettings struct {
+	// (Optional) Role required to use the command. Must be "trusted",
+	// "moderator", "admin", or "owner". Default: Empty (anyone)
+	Role string `json:"role"`
+	// (Optional) Minimum time between uses of the command. Default: No
+	// cooldown
+	Cooldown Cooldown `json:"cooldown"`
+}
+
+// Cooldown stores the minimum number of seconds between uses of a command in
+// each scope. Zero disables the cooldown for that scope.
+type Cooldown struct {
+	// (Optional) Seconds between uses by the same user. Default: 0
+	User float64 `json:"user"`
+	// (Optional) Seconds between uses in the same channel. Default: 0
+	Channel float64 `json:"channel"`
+	// (Optional) Seconds between uses anywhere on a client. Default: 0
+	Global float64 `json:"global"`
+	// (Optional) Whether to ignore uses during a cooldown without replying.
+	// Default: false
+	Silent bool `json:"silent"`
+}
+
+// commandInfo maps commands loaded from the config file to their additional
+// configuration values.
+var commandInfo = map[*ircutil.Command]*CommandInfo{}
+
+// commandInfoMu guards commandInfo, which is replaced whenever a config is
+// loaded.
+var commandInfoMu sync.RWMutex
+
+// parseCommandInfo parses additional configuration values for each command in
+// a config file and stores them for later lookup.
+func parseCommandInfo(raw []byte, config *Config) error {
+	// Parse the commands section a second time into our own struct.
+	info := struct {
+		Commands []CommandInfo `json:"commands"`
+	}{}
+	err := json.Unmarshal(raw, &info)
+	if err != nil {
+		return err
+	}
+
+	// Parse arguments strings, and store values using the command they belong
+	// to, replacing any from a previously loaded config.
+	infos := map[*ircutil.Command]*CommandInfo{}
+	for i := range config.Commands {
+		c := &config.Commands[i]
+		info.Commands[i].Spec, err = ParseArgSpec(c.Arguments)
+		if err != nil {
+			return fmt.Errorf("%s in command %s", err, c.Function)
+		}
+
+		// Treat the older admin setting as the admin role, since ircutil
+		// checks it against nicknames anyone can take.
+		if c.Settings.Admin {
+			if info.Commands[i].Settings.Role != "owner" {
+				info.Commands[i].Settings.Role = "admin"
+			}
+			c.Settings.Admin = false
+		}
+
+		// Compile the pattern, if there is one.
+		if len(info.Commands[i].Pattern) > 0 {
+			info.Commands[i].Regexp, err = regexp.Compile(info.Commands[i].Pattern)
+			if err != nil {
+				return fmt.Errorf("parsing pattern: %s in command %s", err,
+					c.Function)
+			}
+		}
+		infos[c] = &info.Commands[i]
+	}
+	commandInfoMu.Lock()
+	commandInfo = infos
+	commandInfoMu.Unlock()
+	return nil
+}
+
+// GetCommandInfo returns additional configuration values for a command. If the
+// command wasn't loaded from a config file, such as one added at runtime, only
+// its arguments string is used.
+func GetCommandInfo(command *ircutil.Command) *CommandInfo {
+	commandInfoMu.RLock()
+	info, ok := commandInfo[command]
+	commandInfoMu.RUnlock()
+	if ok {
+		return info
+	}
+	spec, _ := ParseArgSpec(command.Arguments)
+	return &CommandInfo{Spec: spec}
+}
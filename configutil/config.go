@@ -14,18 +14,110 @@ type Config struct {
 	// (Optional) Default command settings. Can be overrode for individual
 	// commands. Default: All nested defaults
 	Settings ircutil.Settings `json:"settings"`
+	// (Optional) Maximum number of lines a long response can be split into.
+	// Default: 0 (no limit)
+	MaxLines int `json:"maxLines"`
+	// (Optional) Whether admins and owners granted by services account are
+	// sent a private message when a command fails unexpectedly. Default: false
+	NotifyAdmins bool `json:"notifyAdmins"`
+	// (Optional) Map of role names to hostmask patterns or services accounts
+	// (prefixed with "$a:") that hold them on every client. Roles can also be
+	// granted at runtime. Default: Empty
+	Roles map[string][]string `json:"roles"`
 	// List of servers that can be used in a client.
 	Servers []ircutil.Server `json:"servers"`
 	// List of users that can be used in a client.
 	Users []ircutil.User `json:"users"`
 	// List of clients. Connections between user and server.
 	Clients []ircutil.Client `json:"clients"`
+	// (Optional) List of external programs that provide command functions.
+	// Default: Empty
+	Plugins []Plugin `json:"plugins"`
+	// (Optional) Directory to load Lua scripts from. Default: scripts
+	Scripts string `json:"scripts"`
+	// (Optional) Settings for the cache used to store responses from web
+	// APIs. Default: All nested defaults
+	Cache Cache `json:"cache"`
+	// (Optional) Settings for anime commands. Default: All nested defaults
+	Anime Anime `json:"anime"`
 	// List of commands to be used for all clients. To use different commands
 	// with different clients, run multiple instances of the program with
 	// different configuration files.
 	Commands []ircutil.Command `json:"commands"`
 }
 
+// Plugin stores settings for an external program that provides command
+// functions over a JSON line protocol on its standard input and output.
+type Plugin struct {
+	// Name used in function keys, such as "exec/<name>.<function>".
+	Name string `json:"name"`
+	// Path of the program to run.
+	Path string `json:"path"`
+	// (Optional) Arguments to run the program with. Default: Empty
+	Args []string `json:"args"`
+	// Names of the functions the program provides.
+	Functions []string `json:"functions"`
+	// (Optional) Seconds to wait for a function to finish before giving up on
+	// it. Default: 30
+	Timeout float64 `json:"timeout"`
+}
+
+// Cache stores settings for the cache used to store responses from web APIs.
+type Cache struct {
+	// (Optional) Where cached responses are stored. Must be "memory",
+	// "memcached", or "redis". Default: memory
+	Backend string `json:"backend"`
+	// (Optional) Address of the memcached or Redis server, such as
+	// "127.0.0.1:11211". Required for memcached and Redis. Default: Empty
+	Address string `json:"address"`
+	// (Optional) Maximum number of responses kept in memory, or 0 for no
+	// limit. Default: 1000
+	Size int `json:"size"`
+}
+
+// Anime stores settings for anime commands.
+type Anime struct {
+	// (Optional) Database used to look up shows when a channel hasn't chosen
+	// one. Must be "kitsu", "anilist", or "mal". Default: kitsu
+	Provider string `json:"provider"`
+	// (Optional) Base URLs of provider APIs, keyed by provider name, such as a
+	// local stand-in for testing. Default: Each provider's public API
+	URLs map[string]string `json:"urls"`
+	// (Optional) Seconds to wait for each request to a provider before giving
+	// up on it. Default: 10
+	Timeout float64 `json:"timeout"`
+	// (Optional) Number of times a request is retried when a provider is busy
+	// or having problems. Default: 2
+	Retries int `json:"retries"`
+	// (Optional) User-Agent header sent to providers.
+	// Default: inami-irc-bot (+https://github.com/jasonpuglisi/inami-irc-bot)
+	UserAgent string `json:"userAgent"`
+	// (Optional) Minutes between checks for new episodes of airing shows in
+	// each channel, unless a channel sets its own. Default: 30
+	Interval float64 `json:"interval"`
+	// (Optional) Seconds a watch party waits for everyone to be ready before
+	// starting anyway. Default: 120
+	ReadyTimeout float64 `json:"readyTimeout"`
+	// (Optional) Minutes between episodes of a marathon, unless a channel sets
+	// its own. Default: 5
+	Intermission float64 `json:"intermission"`
+	// (Optional) Most episodes a single marathon can play. Default: 12
+	MaxMarathon int `json:"maxMarathon"`
+	// (Optional) Seconds to cache each type of response for.
+	// Default: All nested defaults
+	TTL AnimeTTL `json:"ttl"`
+}
+
+// AnimeTTL stores how long each type of anime provider response is cached.
+type AnimeTTL struct {
+	// (Optional) Seconds to cache search results for. Default: 3600
+	Search float64 `json:"search"`
+	// (Optional) Seconds to cache show data for. Default: 21600
+	Show float64 `json:"show"`
+	// (Optional) Seconds to cache episode lists for. Default: 43200
+	Episodes float64 `json:"episodes"`
+}
+
 // GetConfig opens a config file at the given path and parses it into a config
 // struct with default values applied.
 func GetConfig(path string) (*Config, error) {
@@ -51,6 +143,12 @@ func GetConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	// Parse additional command values that ircutil doesn't handle.
+	err = parseCommandInfo(raw, config)
+	if err != nil {
+		return nil, err
+	}
+
 	// Return parsed and updated configuration.
 	return config, nil
 }
@@ -63,6 +161,27 @@ func newConfig() *Config {
 			Symbol:        "!",
 			Scope:         []string{"channel"},
 		},
+		Scripts: "scripts",
+		Cache: Cache{
+			Backend: "memory",
+			Size:    1000,
+		},
+		Anime: Anime{
+			Provider:     "kitsu",
+			Timeout:      10,
+			Retries:      2,
+			Interval:     30,
+			ReadyTimeout: 120,
+			Intermission: 5,
+			MaxMarathon:  12,
+			UserAgent: "inami-irc-bot " +
+				"(+https://github.com/jasonpuglisi/inami-irc-bot)",
+			TTL: AnimeTTL{
+				Search:   3600,
+				Show:     21600,
+				Episodes: 43200,
+			},
+		},
 	}
 }
 
@@ -91,6 +210,14 @@ func setConfigDefaults(config *Config) {
 		}
 	}
 
+	// Update defaults for each plugin.
+	for i := range config.Plugins {
+		p := &config.Plugins[i]
+		if p.Timeout <= 0 {
+			p.Timeout = 30
+		}
+	}
+
 	// Update details for each command's settings.
 	for i := range config.Commands {
 		cs := &config.Commands[i].Settings
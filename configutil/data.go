@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"io/ioutil"
+	"sync"
 
 	"github.com/jasonpuglisi/ircutil"
 )
 
+// dataMu guards persistent data, which is shared by every client and used by
+// commands and background tasks at the same time.
+var dataMu sync.Mutex
+
 // GetData opens a data file at the given path and parses it into a data
 // struct.
 func GetData(path string) (ircutil.Data, error) {
@@ -60,6 +65,8 @@ func GetValue(client *ircutil.Client, keys []string) (string, error) {
 	}
 
 	// Return value.
+	dataMu.Lock()
+	defer dataMu.Unlock()
 	buildMap(client, keys)
 	return client.Data[clientPrefix][scope][owner][group][key], nil
 }
@@ -82,11 +89,80 @@ func SetValue(client *ircutil.Client, keys []string, value string) error {
 	}
 
 	// Set value and write data file.
+	dataMu.Lock()
+	defer dataMu.Unlock()
 	buildMap(client, keys)
 	client.Data[clientPrefix][scope][owner][group][key] = value
 	return writeData(client)
 }
 
+// GetGroup gets all keys and values in a data group using a keys array in the
+// format [ "scope", "owner", "data_group" ], with the same scopes as GetValue.
+// The returned map is a copy and can be modified.
+func GetGroup(client *ircutil.Client, keys []string) (map[string]string,
+	error) {
+	// Error if number of key parameters is wrong.
+	if len(keys) != 3 {
+		return nil, errors.New("getting group: invalid number of key parameters")
+	}
+
+	// Set individual key parameters.
+	clientPrefix, scope, owner, group := ircutil.GetClientPrefix(client),
+		keys[0], keys[1], keys[2]
+
+	// Error if scope is invalid.
+	if scope != "user" && scope != "channel" && scope != "client" {
+		return nil, errors.New("getting group: invalid scope")
+	}
+
+	// Return copy of group.
+	dataMu.Lock()
+	defer dataMu.Unlock()
+	buildMap(client, keys)
+	values := map[string]string{}
+	for k, v := range client.Data[clientPrefix][scope][owner][group] {
+		values[k] = v
+	}
+	return values, nil
+}
+
+// GetOwners returns the owners that have data in a scope, such as every
+// channel with saved data.
+func GetOwners(client *ircutil.Client, scope string) []string {
+	dataMu.Lock()
+	defer dataMu.Unlock()
+	var owners []string
+	for owner := range client.Data[ircutil.GetClientPrefix(client)][scope] {
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// DeleteValue removes a value from persistent data using a keys array in the
+// same format and with the same scopes as GetValue.
+func DeleteValue(client *ircutil.Client, keys []string) error {
+	// Error if number of key parameters is wrong.
+	if len(keys) != 4 {
+		return errors.New("deleting data: invalid number of key parameters")
+	}
+
+	// Set individual key parameters.
+	clientPrefix, scope, owner, group, key := ircutil.GetClientPrefix(client),
+		keys[0], keys[1], keys[2], keys[3]
+
+	// Error if scope is invalid.
+	if scope != "user" && scope != "channel" && scope != "client" {
+		return errors.New("deleting data: invalid scope")
+	}
+
+	// Delete value and write data file.
+	dataMu.Lock()
+	defer dataMu.Unlock()
+	buildMap(client, keys)
+	delete(client.Data[clientPrefix][scope][owner][group], key)
+	return writeData(client)
+}
+
 // buildMap ensures all levels of a map exist, and creates them if necessary.
 // It uses a keys array in the same format and with the same scopes as
 // GetValue.
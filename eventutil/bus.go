@@ -0,0 +1,95 @@
+package eventutil
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Filter limits the events a handler receives. Empty fields match everything.
+type Filter struct {
+	// Only receive events from this client.
+	Client *ircutil.Client
+	// Only receive events in this channel.
+	Channel string
+}
+
+// Handler handles an event. Each handler runs in its own goroutine, so a slow
+// one doesn't hold up the client or other handlers, and handlers for
+// different events can run at the same time.
+type Handler func(event *Event)
+
+// subscription stores a handler and what it subscribed to.
+type subscription struct {
+	id        int
+	eventType string
+	filter    Filter
+	handler   Handler
+}
+
+// subscriptions stores every active subscription in the order they were made.
+var subscriptions []subscription
+
+// nextID is the identifier of the next subscription.
+var nextID int
+
+// subscriptionsMu guards subscriptions and nextID.
+var subscriptionsMu sync.RWMutex
+
+// Subscribe registers a handler for events of a type, such as "JOIN" or "353",
+// or "*" for every type. It returns a function that removes the subscription.
+func Subscribe(eventType string, filter Filter, handler Handler) func() {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	nextID++
+	id := nextID
+	subscriptions = append(subscriptions, subscription{id: id,
+		eventType: strings.ToUpper(eventType), filter: filter, handler: handler})
+	return func() {
+		subscriptionsMu.Lock()
+		defer subscriptionsMu.Unlock()
+		for i, s := range subscriptions {
+			if s.id == id {
+				subscriptions = append(subscriptions[:i:i], subscriptions[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish passes an event to every matching handler without waiting for them.
+// Handlers share the event, so they must not modify it.
+func Publish(e *Event) {
+	// Copy matching subscriptions so handlers can subscribe and unsubscribe.
+	subscriptionsMu.RLock()
+	var matched []subscription
+	for _, s := range subscriptions {
+		if (s.eventType == "*" || s.eventType == e.Type) &&
+			(s.filter.Client == nil || s.filter.Client == e.Client) &&
+			(len(s.filter.Channel) < 1 ||
+				strings.EqualFold(s.filter.Channel, e.Channel)) {
+			matched = append(matched, s)
+		}
+	}
+	subscriptionsMu.RUnlock()
+
+	// Start each handler.
+	for _, s := range matched {
+		go run(s.handler, e)
+	}
+}
+
+// run runs a handler, recovering from a panic so one handler can't stop the
+// others or the client.
+func run(handler Handler, e *Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			ircutil.Log(e.Client, fmt.Sprintf("Panic handling %s event: %v\n%s",
+				e.Type, r, debug.Stack()))
+		}
+	}()
+	handler(e)
+}
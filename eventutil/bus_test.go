@@ -0,0 +1,60 @@
+package eventutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+func TestPublish(t *testing.T) {
+	client := &ircutil.Client{ServerID: "test", UserID: "bus"}
+	other := &ircutil.Client{ServerID: "test", UserID: "other"}
+
+	// A handler that never returns must not hold up the others.
+	block := make(chan struct{})
+	defer close(block)
+	defer Subscribe("JOIN", Filter{}, func(e *Event) { <-block })()
+	got := make(chan string, 10)
+	defer Subscribe("join", Filter{Channel: "#CHAN"}, func(e *Event) {
+		got <- "join " + e.Channel
+	})()
+	defer Subscribe("*", Filter{Client: client}, func(e *Event) {
+		got <- "any " + e.Type
+	})()
+	defer Subscribe("PART", Filter{}, func(e *Event) { panic("part") })()
+
+	for _, p := range []struct {
+		client *ircutil.Client
+		line   string
+	}{
+		{client, ":nick!u@h JOIN #chan"},
+		{other, ":nick!u@h JOIN #other"},
+		{other, ":nick!u@h PART #chan"},
+	} {
+		e, err := Parse(p.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Client = p.client
+		Publish(e)
+	}
+
+	want := map[string]bool{"join #chan": true, "any JOIN": true}
+	for len(want) > 0 {
+		select {
+		case s := <-got:
+			if !want[s] {
+				t.Fatalf("unexpected event %q", s)
+			}
+			delete(want, s)
+		case <-time.After(time.Second):
+			t.Fatalf("missing events %v", want)
+		}
+	}
+	select {
+	case s := <-got:
+		t.Errorf("unexpected event %q", s)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,125 @@
+package eventutil
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Event stores a parsed IRC message received by a client.
+type Event struct {
+	// Client that received the message.
+	Client *ircutil.Client
+	// Command or numeric of the message, such as "JOIN" or "353", in upper
+	// case.
+	Type string
+	// Full source of the message, such as "nick!user@host" or a server name.
+	Source string
+	// Nickname part of the source.
+	Nick string
+	// Channel the event happened in, if any. For INVITE, the channel invited
+	// to.
+	Channel string
+	// Nickname the event is about, if any. For NICK, the new nickname. For
+	// KICK, the nickname kicked. For INVITE, the nickname invited. For MODE
+	// on a user, the user.
+	Target string
+	// Trailing text, such as a message, topic, or reason.
+	Text string
+	// All parameters, including the trailing text.
+	Params []string
+	// Message tags, if the server sent any.
+	Tags map[string]string
+	// Raw line as received.
+	Raw string
+}
+
+// Parse parses a raw IRC line into an event without a client.
+func Parse(line string) (*Event, error) {
+	e := &Event{Raw: line, Tags: map[string]string{}}
+	line = strings.TrimRight(line, "\r\n")
+
+	// Parse tags and source.
+	if strings.HasPrefix(line, "@") {
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, errors.New("parsing event: no command")
+		}
+		for _, t := range strings.Split(line[1:i], ";") {
+			kv := strings.SplitN(t, "=", 2)
+			if len(kv) == 2 {
+				e.Tags[kv[0]] = kv[1]
+			} else {
+				e.Tags[kv[0]] = ""
+			}
+		}
+		line = strings.TrimLeft(line[i:], " ")
+	}
+	if strings.HasPrefix(line, ":") {
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, errors.New("parsing event: no command")
+		}
+		e.Source = line[1:i]
+		e.Nick = strings.SplitN(e.Source, "!", 2)[0]
+		line = strings.TrimLeft(line[i:], " ")
+	}
+
+	// Parse command and parameters, keeping spaces in the trailing one.
+	trailing, hasTrailing := "", false
+	if i := strings.Index(line, " :"); i >= 0 {
+		trailing, hasTrailing = line[i+2:], true
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 1 {
+		return nil, errors.New("parsing event: no command")
+	}
+	e.Type = strings.ToUpper(fields[0])
+	e.Params = fields[1:]
+	if hasTrailing {
+		e.Params = append(e.Params, trailing)
+	}
+	if len(e.Params) > 0 {
+		e.Text = e.Params[len(e.Params)-1]
+	}
+
+	// Fill in the channel and target for commands that have them.
+	param := e.Param
+	switch e.Type {
+	case "JOIN", "PART", "TOPIC":
+		e.Channel = param(0)
+	case "PRIVMSG", "NOTICE":
+		if ircutil.IsChannel(param(0)) {
+			e.Channel = param(0)
+		} else {
+			e.Target = param(0)
+		}
+	case "KICK":
+		e.Channel, e.Target = param(0), param(1)
+	case "NICK":
+		e.Target = param(0)
+	case "INVITE":
+		e.Target, e.Channel = param(0), param(1)
+	case "MODE":
+		if ircutil.IsChannel(param(0)) {
+			e.Channel = param(0)
+		} else {
+			e.Target = param(0)
+		}
+	case "353":
+		e.Channel = param(2)
+	case "366", "332", "333":
+		e.Channel = param(1)
+	}
+	return e, nil
+}
+
+// Param returns a parameter of the event, or an empty string if it's missing.
+func (e *Event) Param(i int) string {
+	if i < len(e.Params) {
+		return e.Params[i]
+	}
+	return ""
+}
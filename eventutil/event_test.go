@@ -0,0 +1,66 @@
+package eventutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want *Event
+		err  bool
+	}{
+		{"PING :irc.server", &Event{Type: "PING", Text: "irc.server",
+			Params: []string{"irc.server"}}, false},
+		{":nick!user@host PRIVMSG #chan :hello  there\r\n", &Event{
+			Type: "PRIVMSG", Source: "nick!user@host", Nick: "nick",
+			Channel: "#chan", Text: "hello  there",
+			Params: []string{"#chan", "hello  there"}}, false},
+		{":nick!user@host privmsg bot :hi", &Event{Type: "PRIVMSG",
+			Source: "nick!user@host", Nick: "nick", Target: "bot", Text: "hi",
+			Params: []string{"bot", "hi"}}, false},
+		{"@account=Acct;time=x :nick!u@h JOIN #chan Acct :Real Name", &Event{
+			Type: "JOIN", Source: "nick!u@h", Nick: "nick", Channel: "#chan",
+			Text: "Real Name", Params: []string{"#chan", "Acct", "Real Name"},
+			Tags: map[string]string{"account": "Acct", "time": "x"}}, false},
+		{"@draft/flag :nick!u@h PART #chan", &Event{Type: "PART",
+			Source: "nick!u@h", Nick: "nick", Channel: "#chan", Text: "#chan",
+			Params: []string{"#chan"}, Tags: map[string]string{"draft/flag": ""}},
+			false},
+		{":op!u@h KICK #chan victim :bye", &Event{Type: "KICK", Source: "op!u@h",
+			Nick: "op", Channel: "#chan", Target: "victim", Text: "bye",
+			Params: []string{"#chan", "victim", "bye"}}, false},
+		{":old!u@h NICK :new", &Event{Type: "NICK", Source: "old!u@h",
+			Nick: "old", Target: "new", Text: "new", Params: []string{"new"}},
+			false},
+		{":op!u@h INVITE bot #chan", &Event{Type: "INVITE", Source: "op!u@h",
+			Nick: "op", Channel: "#chan", Target: "bot", Text: "#chan",
+			Params: []string{"bot", "#chan"}}, false},
+		{":irc 353 bot = #chan :@op +voice nick", &Event{Type: "353",
+			Source: "irc", Nick: "irc", Channel: "#chan", Text: "@op +voice nick",
+			Params: []string{"bot", "=", "#chan", "@op +voice nick"}}, false},
+		{":irc MODE bot +i", &Event{Type: "MODE", Source: "irc", Nick: "irc",
+			Target: "bot", Text: "+i", Params: []string{"bot", "+i"}}, false},
+		{"", nil, true},
+		{":source", nil, true},
+		{"@tags", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.line)
+		if (err != nil) != tt.err {
+			t.Errorf("Parse(%q) error = %v, want error %t", tt.line, err, tt.err)
+			continue
+		}
+		if got == nil {
+			continue
+		}
+		if tt.want.Tags == nil {
+			tt.want.Tags = map[string]string{}
+		}
+		tt.want.Raw = tt.line
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package execcmd
+
+import (
+	"fmt"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/modutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+func init() {
+	modutil.Register("execcmd", modutil.Module{
+		Init: func(cmdMap ircutil.CmdMap, config *configutil.Config) {
+			Init(cmdMap, config.Plugins)
+		},
+	})
+}
+
+// Init adds the functions of each configured plugin to the command map. Plugin
+// programs are started the first time one of their functions is used, and
+// restarted if they exit.
+func Init(cmdMap ircutil.CmdMap, plugins []configutil.Plugin) {
+	for i := range plugins {
+		p := &plugins[i]
+		h := newHost(*p)
+		for _, f := range p.Functions {
+			ircutil.AddCommand(cmdMap, fmt.Sprintf("exec/%s.%s", p.Name, f),
+				h.function(f))
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package execcmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// request stores a line sent to a plugin program.
+type request struct {
+	// Type of line: "invoke" to run a function, or "data" to answer a get or
+	// set.
+	Type string `json:"type"`
+	// Invocation the line belongs to.
+	ID string `json:"id"`
+	// Get or set the line answers, for data lines.
+	Request string `json:"request,omitempty"`
+	// Function to run, for invoke lines.
+	Function string `json:"function,omitempty"`
+	// Message source, target, and arguments, for invoke lines.
+	Source string            `json:"source,omitempty"`
+	Target string            `json:"target,omitempty"`
+	Args   []string          `json:"args,omitempty"`
+	Named  map[string]string `json:"named,omitempty"`
+	// Value read, for data lines.
+	Value string `json:"value,omitempty"`
+	// Error message, for data lines that failed.
+	Error string `json:"error,omitempty"`
+}
+
+// response stores a line received from a plugin program.
+type response struct {
+	// Type of line: "reply" to send a response, "get" or "set" to access
+	// persistent data, or "done" to finish an invocation.
+	Type string `json:"type"`
+	// Invocation the line belongs to.
+	ID string `json:"id"`
+	// Identifier echoed back in the answer to a get or set.
+	Request string `json:"request"`
+	// Text to send, for reply lines.
+	Text string `json:"text"`
+	// Data keys in the format used by configutil.GetValue, for get and set
+	// lines.
+	Keys []string `json:"keys"`
+	// Value to write, for set lines.
+	Value string `json:"value"`
+}
+
+// call stores an invocation waiting on a plugin program.
+type call struct {
+	client  *ircutil.Client
+	message *ircutil.Message
+	done    chan struct{}
+}
+
+// host runs a plugin program and routes lines between it and invocations.
+type host struct {
+	plugin configutil.Plugin
+
+	// mu guards the process, its input, and the invocation counter.
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	input  *writer
+	nextID int
+
+	// callsMu guards calls.
+	callsMu sync.Mutex
+	calls   map[string]*call
+}
+
+// newHost creates a host for a plugin without starting its program.
+func newHost(plugin configutil.Plugin) *host {
+	return &host{plugin: plugin, calls: map[string]*call{}}
+}
+
+// function returns a command function that invokes a function of the plugin
+// program and waits for it to finish or time out.
+func (h *host) function(name string) func(*ircutil.Client, *ircutil.Command,
+	*ircutil.Message) {
+	return func(client *ircutil.Client, command *ircutil.Command,
+		message *ircutil.Message) {
+		// Register the invocation so lines from the program can find it.
+		c := &call{client: client, message: message, done: make(chan struct{})}
+		h.mu.Lock()
+		h.nextID++
+		id := strconv.Itoa(h.nextID)
+		h.mu.Unlock()
+		h.callsMu.Lock()
+		h.calls[id] = c
+		h.callsMu.Unlock()
+		defer func() {
+			h.callsMu.Lock()
+			delete(h.calls, id)
+			h.callsMu.Unlock()
+		}()
+
+		// Send the invocation, starting the program if necessary.
+		err := h.send(client, request{Type: "invoke", ID: id, Function: name,
+			Source: message.Source, Target: message.Target, Args: message.Args,
+			Named: configutil.GetArgs(command, message)})
+		if err != nil {
+			ircutil.Log(client, fmt.Sprintf("Error invoking plugin %s: %s",
+				h.plugin.Name, err))
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Error running command, try again later")
+			return
+		}
+
+		// Wait for the program to finish the invocation.
+		timeout := time.Duration(h.plugin.Timeout * float64(time.Second))
+		select {
+		case <-c.done:
+		case <-time.After(timeout):
+			ircutil.Log(client, fmt.Sprintf("Plugin %s timed out running %s",
+				h.plugin.Name, name))
+		}
+	}
+}
+
+// send queues a line for the plugin program, starting it for the client if it
+// isn't running. Lines are written in the background, so answers sent while
+// handling the program's output can't block on its input.
+func (h *host) send(client *ircutil.Client, req request) error {
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cmd == nil {
+		err = h.start(client)
+		if err != nil {
+			return err
+		}
+	}
+	return h.input.queue(append(raw, '\n'))
+}
+
+// start launches the plugin program and begins reading its output. Problems
+// with the program are logged for the client that started it. It must be
+// called with mu held.
+func (h *host) start(client *ircutil.Client) error {
+	cmd := exec.Command(h.plugin.Path, h.plugin.Args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	h.cmd, h.input = cmd, newWriter(client, h.plugin.Name, stdin)
+	go h.read(client, cmd, stdout, h.input)
+	return nil
+}
+
+// read handles lines from a plugin program until it exits, then marks the
+// program as stopped so it is restarted on the next invocation.
+func (h *host) read(client *ircutil.Client, cmd *exec.Cmd, stdout io.Reader,
+	input *writer) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		resp := response{}
+		err := json.Unmarshal(scanner.Bytes(), &resp)
+		if err != nil {
+			ircutil.Log(client, fmt.Sprintf("Plugin %s sent an invalid line: %s",
+				h.plugin.Name, err))
+			continue
+		}
+		h.handle(resp)
+	}
+
+	// Clear the process if it hasn't already been replaced.
+	input.close()
+	cmd.Wait()
+	h.mu.Lock()
+	if h.cmd == cmd {
+		h.cmd, h.input = nil, nil
+	}
+	h.mu.Unlock()
+}
+
+// handle acts on a line from a plugin program for the invocation it belongs
+// to. Lines for finished invocations are dropped.
+func (h *host) handle(resp response) {
+	h.callsMu.Lock()
+	c, ok := h.calls[resp.ID]
+	h.callsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	switch resp.Type {
+	case "reply":
+		msgutil.SendResponse(c.client, c.message.Source, c.message.Target,
+			resp.Text)
+
+	case "get", "set":
+		// Read or write persistent data in the plugin's own group and send the
+		// result back.
+		ans := request{Type: "data", ID: resp.ID, Request: resp.Request}
+		var err error
+		group := "plugins/" + h.plugin.Name
+		if len(resp.Keys) != 4 || resp.Keys[2] != group {
+			err = fmt.Errorf("data group must be %s", group)
+		} else if resp.Type == "get" {
+			ans.Value, err = configutil.GetValue(c.client, resp.Keys)
+		} else {
+			err = configutil.SetValue(c.client, resp.Keys, resp.Value)
+		}
+		if err != nil {
+			ans.Error = err.Error()
+		}
+		err = h.send(c.client, ans)
+		if err != nil {
+			ircutil.Log(c.client, err.Error())
+		}
+
+	case "done":
+		h.callsMu.Lock()
+		delete(h.calls, resp.ID)
+		h.callsMu.Unlock()
+		close(c.done)
+
+	default:
+		ircutil.Log(c.client, fmt.Sprintf("Plugin %s sent unknown line type %s",
+			h.plugin.Name, resp.Type))
+	}
+}
+
+// errStopped is returned when queueing a line for a program that has stopped.
+var errStopped = errors.New("writing to plugin: program stopped")
+
+// writer writes queued lines to a plugin program's input one at a time. The
+// queue has no limit, so queueing never waits on the program.
+type writer struct {
+	client *ircutil.Client
+	name   string
+
+	// mu guards lines and closed, and cond signals changes to them.
+	mu     sync.Mutex
+	cond   *sync.Cond
+	lines  [][]byte
+	closed bool
+}
+
+// newWriter creates a writer for a plugin's input and starts writing to it.
+func newWriter(client *ircutil.Client, name string,
+	input io.WriteCloser) *writer {
+	w := &writer{client: client, name: name}
+	w.cond = sync.NewCond(&w.mu)
+	go w.run(input)
+	return w
+}
+
+// queue adds a line to be written, or returns an error if the writer is
+// closed.
+func (w *writer) queue(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errStopped
+	}
+	w.lines = append(w.lines, line)
+	w.cond.Signal()
+	return nil
+}
+
+// close stops the writer once it has written every queued line.
+func (w *writer) close() {
+	w.mu.Lock()
+	w.closed = true
+	w.cond.Signal()
+	w.mu.Unlock()
+}
+
+// run writes queued lines until the writer is closed or a write fails, then
+// closes the input.
+func (w *writer) run(input io.WriteCloser) {
+	defer input.Close()
+	for {
+		// Wait for a line, stopping once the queue is closed and empty.
+		w.mu.Lock()
+		for len(w.lines) < 1 && !w.closed {
+			w.cond.Wait()
+		}
+		if len(w.lines) < 1 {
+			w.mu.Unlock()
+			return
+		}
+		line := w.lines[0]
+		w.lines = w.lines[1:]
+		w.mu.Unlock()
+
+		// Drop the rest of the queue if the program stopped reading.
+		if _, err := input.Write(line); err != nil {
+			ircutil.Log(w.client, fmt.Sprintf("Plugin %s stopped reading input: %s",
+				w.name, err))
+			w.mu.Lock()
+			w.closed, w.lines = true, nil
+			w.mu.Unlock()
+			return
+		}
+	}
+}
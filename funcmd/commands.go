@@ -2,13 +2,32 @@ package funcmd
 
 import (
 	"math/rand"
+	"sync"
 
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/modutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
 	"github.com/jasonpuglisi/ircutil"
 )
 
+// subscribeOnce makes sure events are only subscribed to once, even if the
+// module is initialized again.
+var subscribeOnce sync.Once
+
+func init() {
+	modutil.Register("funcmd", modutil.Module{
+		Init: func(cmdMap ircutil.CmdMap, config *configutil.Config) {
+			Init(cmdMap)
+			subscribeOnce.Do(subscribeLastLines)
+		},
+	})
+}
+
 // Init adds funcmd's functions to the command map.
 func Init(cmdMap ircutil.CmdMap) {
 	ircutil.AddCommand(cmdMap, "inami/funcmd.EightBall", EightBall)
+	ircutil.AddCommand(cmdMap, "inami/funcmd.Karma", Karma)
+	ircutil.AddCommand(cmdMap, "inami/funcmd.Correct", Correct)
 }
 
 // EightBall sends a random magic 8-ball response.
@@ -22,6 +41,6 @@ func EightBall(client *ircutil.Client, command *ircutil.Command,
 		"Better not tell you now", "Cannot predict now",
 		"Concentrate and ask again", "Don't count on it", "My reply is no",
 		"My sources say no", "Outlook not so good", "Very doubtful"}
-	ircutil.SendResponse(client, message.Source, message.Target,
+	msgutil.SendResponse(client, message.Source, message.Target,
 		responses[rand.Intn(len(responses))])
 }
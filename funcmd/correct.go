@@ -0,0 +1,95 @@
+package funcmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/eventutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// lastLine stores a message and when it was sent.
+type lastLine struct {
+	text string
+	time time.Time
+}
+
+// lastLines maps client prefixes, channels, and nicknames to the last message
+// sent there.
+var lastLines = map[string]lastLine{}
+
+// lastLinesMu guards lastLines and lastLinesPrune.
+var lastLinesMu sync.Mutex
+
+// lastLinesPrune is when old lines were last removed.
+var lastLinesPrune time.Time
+
+// lastLineAge is how long a message can be corrected for.
+const lastLineAge = time.Hour
+
+// subscribeLastLines subscribes to channel messages to remember the last one
+// each nickname sent, skipping corrections themselves.
+func subscribeLastLines() {
+	eventutil.Subscribe("PRIVMSG", eventutil.Filter{}, func(e *eventutil.Event) {
+		if len(e.Channel) < 1 || strings.HasPrefix(e.Text, "s/") {
+			return
+		}
+		lastLinesMu.Lock()
+		now := clockutil.Now()
+		pruneLastLines(now)
+		lastLines[lastLineKey(e.Client, e.Channel, e.Nick)] = lastLine{e.Text,
+			now}
+		lastLinesMu.Unlock()
+	})
+}
+
+// pruneLastLines removes lines too old to correct, at most once per line age,
+// so nicknames that leave don't keep their lines forever. It must be called
+// with lastLinesMu held.
+func pruneLastLines(now time.Time) {
+	if now.Sub(lastLinesPrune) < lastLineAge {
+		return
+	}
+	lastLinesPrune = now
+	for key, line := range lastLines {
+		if now.Sub(line.time) >= lastLineAge {
+			delete(lastLines, key)
+		}
+	}
+}
+
+// Correct replaces text in the sender's last message, like "s/find/replace/".
+// Function key: inami/funcmd.Correct
+func Correct(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Get the sender's last message in this channel.
+	nick := ircutil.GetNick(message.Source)
+	key := lastLineKey(client, message.Target, nick)
+	lastLinesMu.Lock()
+	last, ok := lastLines[key]
+	lastLinesMu.Unlock()
+	args := configutil.GetArgs(command, message)
+	if !ok || clockutil.Now().Sub(last.time) >= lastLineAge ||
+		!strings.Contains(last.text, args["find"]) {
+		return
+	}
+
+	// Remember and send the corrected message.
+	line := strings.Replace(last.text, args["find"], args["replace"], 1)
+	lastLinesMu.Lock()
+	lastLines[key] = lastLine{line, last.time}
+	lastLinesMu.Unlock()
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("%s meant: %s", nick, line))
+}
+
+// lastLineKey builds a key identifying a nickname in a channel of a client.
+func lastLineKey(client *ircutil.Client, channel string, nick string) string {
+	return strings.ToLower(fmt.Sprintf("%s %s %s",
+		ircutil.GetClientPrefix(client), channel, nick))
+}
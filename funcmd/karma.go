@@ -0,0 +1,57 @@
+package funcmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Karma changes a nickname's karma in a channel with "++" or "--", or shows it
+// if no change is given. Function key: inami/funcmd.Karma
+func Karma(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Set nickname and update scope/owner to match command scope.
+	args := configutil.GetArgs(command, message)
+	nick, change := args["nick"], args["change"]
+	keys := []string{"", "", "fun/karma", strings.ToLower(nick)}
+	configutil.UpdateScope(keys, message.Source, message.Target)
+
+	// Get current karma.
+	karmaStr, err := configutil.GetValue(client, keys)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error getting karma, try again later")
+		return
+	}
+	karma, _ := strconv.Atoi(karmaStr)
+
+	// Update karma if a change was given, refusing changes to your own.
+	switch change {
+	case "++", "--":
+		if strings.EqualFold(nick, ircutil.GetNick(message.Source)) {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"You can't change your own karma")
+			return
+		}
+		if change == "++" {
+			karma++
+		} else {
+			karma--
+		}
+		configutil.SetValue(client, keys, strconv.Itoa(karma))
+	case "":
+	default:
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Invalid karma change, use ++ or --")
+		return
+	}
+
+	// Send response with karma.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("%s has %d karma", nick, karma))
+}
@@ -0,0 +1,12 @@
+module github.com/jasonpuglisi/inami-irc-bot
+
+go 1.21
+
+require (
+	github.com/bradfitz/gomemcache v0.0.0-20230905024940-24af94b03874
+	github.com/gomodule/redigo v1.7.0
+	github.com/jasonpuglisi/ircutil v0.0.0
+	github.com/yuin/gopher-lua v1.1.1
+)
+
+replace github.com/jasonpuglisi/ircutil => ./third_party/ircutil
@@ -0,0 +1,248 @@
+package irctest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/cacheutil"
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/eventutil"
+	"github.com/jasonpuglisi/inami-irc-bot/modutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Timeout is how long Bot methods wait for the client before giving up.
+var Timeout = 5 * time.Second
+
+// Start is the time the first bot's fake clock is set to. Each later bot
+// starts a day after the previous one's clock stopped, so state modules keep
+// between bots, such as cooldowns, has expired.
+var Start = time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// stopped is when the last closed bot's clock stopped, guarded by stoppedMu.
+var stopped time.Time
+
+// stoppedMu guards stopped.
+var stoppedMu sync.Mutex
+
+// Bot is a real client connected to a fake server, configured the same way the
+// bot's main function configures its clients. Only the modules imported by
+// the test are available.
+type Bot struct {
+	// Server the client is connected to.
+	Server *Server
+	// Client under test.
+	Client *ircutil.Client
+	// Clock used by commands, which only moves when advanced.
+	Clock *clockutil.Fake
+	// Path of the client's data file.
+	DataFile string
+
+	dir          string
+	restoreClock func()
+}
+
+// NewBot starts a fake server and connects the first client from a config
+// file's contents to it, replacing the client's server host, port, and
+// security. It waits until the client has joined its channels.
+func NewBot(config string) (*Bot, error) {
+	// Start the server and write files to a temporary directory.
+	server, err := NewServer()
+	if err != nil {
+		return nil, err
+	}
+	b := &Bot{Server: server}
+	b.dir, err = ioutil.TempDir("", "irctest")
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	configFile := filepath.Join(b.dir, "config.json")
+	b.DataFile = filepath.Join(b.dir, "data.json")
+	err = ioutil.WriteFile(configFile, []byte(config), 0644)
+	if err == nil {
+		err = ioutil.WriteFile(b.DataFile, []byte("{}"), 0644)
+	}
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	// Load the config and data the same way main does.
+	cfg, err := configutil.GetConfig(configFile)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	data, err := configutil.GetData(b.DataFile)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	if len(cfg.Clients) < 1 {
+		b.Close()
+		return nil, errors.New("starting bot: no clients in config")
+	}
+	cache, err := cacheutil.New(cfg.Cache)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	cacheutil.Default = cache
+	start := Start
+	stoppedMu.Lock()
+	if !stopped.Before(start) {
+		start = stopped.Add(24 * time.Hour)
+	}
+	stoppedMu.Unlock()
+	b.Clock = clockutil.NewFake(start)
+	b.restoreClock = clockutil.Set(b.Clock)
+	cmdMap := ircutil.InitCommands()
+	modutil.InitAll(cmdMap, cfg)
+	cmdutil.NotifyAdmins = cfg.NotifyAdmins
+	cmdutil.Roles = cfg.Roles
+	cmdutil.Wrap(cmdMap)
+
+	// Set up the first client and point its server at the fake one.
+	client := &cfg.Clients[0]
+	client.Server, err = configutil.GetServer(cfg, client.ServerID)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	client.Server.Host, client.Server.Port = server.Host(), server.Port()
+	client.Server.Secure = false
+	client.User, err = configutil.GetUser(cfg, client.UserID)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	client.Data = data
+	client.DataFile = &b.DataFile
+	client.Commands = cfg.Commands
+	client.CmdMap = cmdMap
+	client.Ready = ready
+	client.Receive = cmdutil.Receive
+	client.Caps = cmdutil.Caps
+	client.Done = make(chan bool, 1)
+	client.Nick = client.User.Nick
+	b.Client = client
+
+	// Connect and wait for each channel to be joined.
+	err = ircutil.EstablishConnection(client)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	for _, c := range client.Channels {
+		channel := strings.Fields(c)[0]
+		_, err = server.Expect(Timeout, func(e *eventutil.Event) bool {
+			return e.Type == "JOIN" && strings.EqualFold(e.Params[0], channel)
+		})
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("joining %s: %s", channel, err)
+		}
+	}
+	return b, nil
+}
+
+// ready joins a client's channels and lets modules set it up, like the bot's
+// own ready function but without delays.
+func ready(client *ircutil.Client) {
+	modutil.ReadyAll(client)
+	for _, c := range client.Channels {
+		fields := strings.Fields(c)
+		pass := ""
+		if len(fields) > 1 {
+			pass = fields[1]
+		}
+		ircutil.SendJoin(client, fields[0], pass)
+	}
+}
+
+// Say sends a message to the bot from a nickname, to a channel or to the bot
+// directly if the target is empty. The nickname's hostmask is
+// "nick!nick@irctest.user".
+func (b *Bot) Say(nick string, target string, text string) error {
+	if len(target) < 1 {
+		target = b.Server.Nick()
+	}
+	return b.Server.Say(Mask(nick), target, text)
+}
+
+// ExpectReply waits for the bot to send a message or notice to a target and
+// returns its text.
+func (b *Bot) ExpectReply(target string) (string, error) {
+	return b.Server.ExpectMessage(Timeout, target)
+}
+
+// ExpectReplies waits for a number of messages or notices to a target and
+// returns their text.
+func (b *Bot) ExpectReplies(target string, n int) ([]string, error) {
+	var replies []string
+	for i := 0; i < n; i++ {
+		r, err := b.ExpectReply(target)
+		if err != nil {
+			return replies, err
+		}
+		replies = append(replies, r)
+	}
+	return replies, nil
+}
+
+// Advance waits until something is waiting on the clock that will fire within
+// a duration, such as a command's timer, then moves the clock forward.
+// Background tasks waiting longer than the duration don't count.
+func (b *Bot) Advance(d time.Duration) error {
+	deadline := time.Now().Add(Timeout)
+	for b.Clock.Due(d) < 1 {
+		if time.Now().After(deadline) {
+			return errors.New("advancing clock: nothing is due")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	b.Clock.Advance(d)
+	return nil
+}
+
+// Value gets a value from the bot's persistent data, using keys in the format
+// used by configutil.GetValue.
+func (b *Bot) Value(keys ...string) (string, error) {
+	return configutil.GetValue(b.Client, keys)
+}
+
+// Close disconnects the bot, stops the server, restores the real clock, and
+// removes temporary files. It waits for the client to stop handling lines, so
+// the next bot doesn't start while this one is still running.
+func (b *Bot) Close() {
+	b.Server.Close()
+	if b.Client != nil {
+		select {
+		case <-b.Client.Done:
+		case <-time.After(Timeout):
+		}
+	}
+	if b.restoreClock != nil {
+		b.restoreClock()
+		stoppedMu.Lock()
+		stopped = b.Clock.Now()
+		stoppedMu.Unlock()
+	}
+	if len(b.dir) > 0 {
+		os.RemoveAll(b.dir)
+	}
+}
+
+// Mask returns the hostmask used for a nickname in messages sent by Bot.Say.
+func Mask(nick string) string {
+	return fmt.Sprintf("%s!%s@irctest.user", nick, nick)
+}
@@ -0,0 +1,513 @@
+package irctest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/jasonpuglisi/inami-irc-bot/animecmd"
+	_ "github.com/jasonpuglisi/inami-irc-bot/funcmd"
+	"github.com/jasonpuglisi/inami-irc-bot/irctest"
+	_ "github.com/jasonpuglisi/inami-irc-bot/utilcmd"
+)
+
+// channel is the channel the example config joins.
+const channel = "#testing"
+
+// kitsuShows stores the shows served by the stub Kitsu API, keyed by id.
+var kitsuShows = map[string]string{
+	"1": `{"id":"1","attributes":{"slug":"frieren","canonicalTitle":"Frieren",` +
+		`"status":"finished","episodeLength":1,"episodeCount":3}}`,
+	"2": `{"id":"2","attributes":{"slug":"frieren-specials",` +
+		`"canonicalTitle":"Frieren Specials","status":"finished",` +
+		`"episodeLength":1,"episodeCount":2}}`,
+	"86": `{"id":"86","attributes":{"slug":"eighty","canonicalTitle":"Eighty",` +
+		`"status":"finished","episodeLength":1,"episodeCount":1}}`,
+	"3": `{"id":"3","attributes":{"slug":"ongoing","canonicalTitle":"Ongoing",` +
+		`"status":"current","episodeLength":1,"episodeCount":12}}`,
+}
+
+// kitsuEpisodes is the episode list of show 1 served by the stub Kitsu API.
+const kitsuEpisodes = `[` +
+	`{"id":"11","attributes":{"number":1,` +
+	`"canonicalTitle":"The Journey's End"}},` +
+	`{"id":"12","attributes":{"number":2,"canonicalTitle":"Episode 2"}},` +
+	`{"id":"13","attributes":{"number":3,"canonicalTitle":"Killing Magic"}}]`
+
+// ongoingAired is the number of episodes of show 3 that have aired so far.
+var ongoingAired int32 = 1
+
+// kitsuServer starts a stub Kitsu API that serves kitsuShows. Searches for
+// "frieren" match shows 1 and 2, and searches for "86" match show 2. Show 3
+// has as many episodes as ongoingAired.
+func kitsuServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		var results []string
+		q := r.URL.Query()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/episodes"):
+			if r.URL.Path == "/anime/1/episodes" {
+				fmt.Fprintf(w, `{"data":%s,"links":{}}`, kitsuEpisodes)
+				return
+			}
+			if r.URL.Path == "/anime/3/episodes" {
+				for i := 1; i <= int(atomic.LoadInt32(&ongoingAired)); i++ {
+					results = append(results, fmt.Sprintf(
+						`{"id":"3%d","attributes":{"number":%d}}`, i, i))
+				}
+			}
+		case len(q.Get("filter[id]")) > 0:
+			if s, ok := kitsuShows[q.Get("filter[id]")]; ok {
+				results = append(results, s)
+			}
+		case q.Get("filter[text]") == "frieren":
+			results = append(results, kitsuShows["1"], kitsuShows["2"])
+		case q.Get("filter[text]") == "86":
+			results = append(results, kitsuShows["2"])
+		}
+		fmt.Fprintf(w, `{"data":[%s],"links":{}}`, strings.Join(results, ","))
+	}))
+}
+
+// newBot starts a bot using the example config's commands, with alice as its
+// owner and anime requests sent to a stub Kitsu API.
+func newBot(t *testing.T) *irctest.Bot {
+	api := kitsuServer()
+	t.Cleanup(api.Close)
+
+	// Load the example config and point it at the stub API.
+	raw, err := ioutil.ReadFile("../config-example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := map[string]interface{}{}
+	if err = json.Unmarshal(raw, &config); err != nil {
+		t.Fatal(err)
+	}
+	delete(config, "plugins")
+	config["roles"] = map[string][]string{"owner": {irctest.Mask("alice")}}
+	config["cache"] = map[string]string{"backend": "memory"}
+	anime := config["anime"].(map[string]interface{})
+	anime["urls"] = map[string]string{"kitsu": api.URL}
+	client := config["clients"].([]interface{})[0].(map[string]interface{})
+	delete(client, "authentication")
+	raw, _ = json.Marshal(config)
+
+	// Start the bot.
+	b, err := irctest.NewBot(string(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(b.Close)
+	return b
+}
+
+// say sends a message to the channel and logs the replies.
+func say(t *testing.T, b *irctest.Bot, nick, text string, n int) []string {
+	t.Helper()
+	b.Say(nick, channel, text)
+	replies, err := b.ExpectReplies(channel, n)
+	if err != nil {
+		t.Fatalf("%s: %s, got %q", text, err, replies)
+	}
+	t.Logf("%s => %q", text, replies)
+	return replies
+}
+
+// reply waits for a reply to a target, failing the test if there isn't one.
+func reply(t *testing.T, b *irctest.Bot, target string) string {
+	t.Helper()
+	r, err := b.ExpectReply(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// quiet makes sure the bot doesn't send anything to a target for a moment.
+func quiet(t *testing.T, b *irctest.Bot, target string) {
+	t.Helper()
+	r, err := b.Server.ExpectMessage(200*time.Millisecond, target)
+	if err == nil {
+		t.Fatalf("expected no reply to %s, got %q", target, r)
+	}
+}
+
+// advance moves the clock forward, failing the test if nothing is waiting.
+func advance(t *testing.T, b *irctest.Bot, d time.Duration) {
+	t.Helper()
+	if err := b.Advance(d); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expect fails a test unless replies match what was expected.
+func expect(t *testing.T, replies []string, want ...string) {
+	t.Helper()
+	if strings.Join(replies, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected %q, got %q", want, replies)
+	}
+}
+
+// tick advances the clock one second at a time through a countdown, and
+// makes sure it counts down from five to "Start!".
+func tick(t *testing.T, b *irctest.Bot) {
+	t.Helper()
+	var replies []string
+	for i := 0; i < 7; i++ {
+		advance(t, b, time.Second)
+		if i > 0 {
+			replies = append(replies, reply(t, b, channel))
+		}
+	}
+	expect(t, replies, "5", "4", "3", "2", "1", "Start!")
+}
+
+func TestCountdown(t *testing.T) {
+	b := newBot(t)
+	expect(t, say(t, b, "bob", ".countdown", 1),
+		"Starting countdown, press play when I say \"Start!\"")
+	tick(t, b)
+}
+
+func TestCountdownStop(t *testing.T) {
+	b := newBot(t)
+	say(t, b, "bob", ".countdown", 1)
+	advance(t, b, time.Second)
+	advance(t, b, time.Second)
+	expect(t, []string{reply(t, b, channel)}, "5")
+	expect(t, say(t, b, "bob", ".countdown stop", 1), "Countdown stopped")
+	quiet(t, b, channel)
+}
+
+func TestSync(t *testing.T) {
+	b := newBot(t)
+	expect(t, say(t, b, "alice", ".sync length 31", 1),
+		"Length must be from 0 to 30 seconds")
+	expect(t, say(t, b, "alice", ".sync length 3", 1),
+		"Countdowns now count down 3 seconds")
+	say(t, b, "bob", ".countdown", 1)
+	var replies []string
+	for i := 0; i < 5; i++ {
+		advance(t, b, time.Second)
+		if i > 0 {
+			replies = append(replies, reply(t, b, channel))
+		}
+	}
+	expect(t, replies, "3", "2", "1", "Start!")
+
+	// Clock countdowns announce a start time, then tick down to it.
+	expect(t, say(t, b, "alice", ".sync mode clock", 1),
+		"Countdowns now use clock")
+	start := b.Clock.Now().Add(30 * time.Second)
+	expect(t, say(t, b, "bob", ".countdown", 1), "Press play at "+
+		start.UTC().Format("15:04:05 MST")+", 30s from now")
+	advance(t, b, 27*time.Second)
+	replies = []string{reply(t, b, channel)}
+	for i := 0; i < 3; i++ {
+		advance(t, b, time.Second)
+		replies = append(replies, reply(t, b, channel))
+	}
+	expect(t, replies, "3", "2", "1", "Start!")
+}
+
+func TestUsage(t *testing.T) {
+	b := newBot(t)
+	expect(t, say(t, b, "bob", ".alias", 1), "Usage: .alias <alias> <show...>")
+	expect(t, say(t, b, "bob", ".watch foo 1 2", 1),
+		"Usage: .watch <alias> [count]")
+	expect(t, say(t, b, "bob", "!karma", 1), "Usage: !karma <nick> [change]")
+}
+
+func TestRoles(t *testing.T) {
+	b := newBot(t)
+
+	// Only admins can make the bot talk.
+	b.Say("bob", "", "say #testing hi")
+	expect(t, []string{reply(t, b, "bob")}, "You need to be admin to use that")
+	b.Say("alice", "", "say #testing hi")
+	expect(t, []string{reply(t, b, channel)}, "hi")
+
+	// Roles can be granted to a services account, which the bot learns from
+	// extended joins.
+	b.Say("alice", "", "grant admin $a:carol")
+	expect(t, []string{reply(t, b, "alice")}, "Granted admin to $a:carol")
+	b.Server.Send(":carol!c@elsewhere JOIN #testing carol :Carol")
+	b.Server.Send(":dave!d@elsewhere JOIN #testing * :Dave")
+	b.Server.Say("dave!d@elsewhere", b.Server.Nick(), "say #testing hi")
+	expect(t, []string{reply(t, b, "dave")}, "You need to be admin to use that")
+	b.Server.Say("carol!c@elsewhere", b.Server.Nick(), "say #testing hey")
+	expect(t, []string{reply(t, b, channel)}, "hey")
+
+	// Admins can't grant their own role.
+	b.Server.Say("carol!c@elsewhere", b.Server.Nick(), "grant admin *!*@*")
+	expect(t, []string{reply(t, b, "carol")},
+		"You need to be owner to manage admin")
+}
+
+func TestIgnore(t *testing.T) {
+	b := newBot(t)
+	b.Say("alice", "", "ignore bob")
+	expect(t, []string{reply(t, b, "alice")}, "Ignoring bob!*@*")
+	b.Say("bob", channel, "!help")
+	quiet(t, b, channel)
+	say(t, b, "carol", "!help", 1)
+
+	// Admins are never ignored.
+	b.Say("alice", "", "ignore alice")
+	reply(t, b, "alice")
+	say(t, b, "alice", "!help", 1)
+
+	// Unignored users are heard again.
+	b.Say("alice", "", "unignore bob")
+	reply(t, b, "alice")
+	say(t, b, "bob", "!help", 1)
+}
+
+func TestMacros(t *testing.T) {
+	b := newBot(t)
+	expect(t, say(t, b, "bob", ".macro add hi Hello", 1),
+		"You need to be trusted to use that")
+	expect(t, say(t, b, "alice", ".macro add Hi Hello, {nick}!", 1),
+		"Saved macro .Hi")
+	expect(t, say(t, b, "bob", ".Hi", 1), "Hello, bob!")
+	expect(t, say(t, b, "bob", ".macros", 1), "Macros: .Hi")
+
+	// Macros are case sensitive like the command managing them.
+	b.Say("bob", channel, ".hi")
+	quiet(t, b, channel)
+
+	// Removed macros stop working.
+	expect(t, say(t, b, "alice", ".macro remove Hi", 1), "Removed macro .Hi")
+	b.Say("bob", channel, ".Hi")
+	quiet(t, b, channel)
+}
+
+func TestPatterns(t *testing.T) {
+	b := newBot(t)
+	expect(t, say(t, b, "alice", "bob++", 1), "bob has 1 karma")
+	expect(t, say(t, b, "bob", "alice--", 1), "alice has -1 karma")
+
+	// The cooldown covers the trigger too.
+	expect(t, say(t, b, "bob", "!karma alice ++", 1),
+		"Slow down, try again in 30s")
+
+	// Corrections use the last line, and ordinary chat gets no usage reply.
+	b.Say("carol", channel, "hello world")
+	quiet(t, b, channel)
+	expect(t, say(t, b, "carol", "s/world//", 1), "carol meant: hello ")
+}
+
+func TestAnime(t *testing.T) {
+	b := newBot(t)
+	expect(t, say(t, b, "bob", ".search frieren", 3), "Shows found on kitsu:",
+		"- [1] Frieren", "- [2] Frieren Specials")
+
+	// Titles with several matches are picked from.
+	expect(t, say(t, b, "bob", ".alias foo frieren", 4), "Shows found on kitsu:",
+		"1. Frieren", "2. Frieren Specials",
+		"Choose one for foo with .pick <number> within a minute")
+	expect(t, say(t, b, "bob", ".pick 1", 1), "Aliased Frieren to foo")
+
+	// Bare numbers are offered along with titles matching them.
+	expect(t, say(t, b, "bob", ".alias bar 86", 4), "Shows found on kitsu:",
+		"1. Eighty", "2. Frieren Specials",
+		"Choose one for bar with .pick <number> within a minute")
+	expect(t, say(t, b, "bob", ".pick 1", 1), "Aliased Eighty to bar")
+
+	// The old argument order still works, for new aliases too.
+	expect(t, say(t, b, "bob", ".alias 1 foo", 2),
+		"The alias comes first now, like .alias foo 1",
+		"Aliased Frieren to foo")
+	expect(t, say(t, b, "bob", ".alias kitsu:86 baz", 2),
+		"The alias comes first now, like .alias baz kitsu:86",
+		"Aliased Eighty to baz")
+	expect(t, say(t, b, "bob", ".next foo", 1),
+		"Next up for Frieren is Episode 1 \"The Journey's End\"")
+
+	// Watch the first episode.
+	say(t, b, "bob", ".watch foo", 1)
+	expect(t, say(t, b, "bob", ".ready", 3), "bob is ready (1/1 ready)",
+		"Everyone's ready",
+		"Starting countdown, press play when I say \"Start!\"")
+	tick(t, b)
+	advance(t, b, 5*time.Second)
+	expect(t, []string{reply(t, b, channel)}, "You're watching Frieren "+
+		"Episode 1 \"The Journey's End\"")
+	if v, _ := b.Value("channel", channel, "anime/progress", "foo"); v != "1" {
+		t.Fatalf("expected progress 1, got %q", v)
+	}
+	expect(t, say(t, b, "bob", ".next foo", 1),
+		"Next up for Frieren is Episode 2")
+
+	// Watched episodes and manual changes are kept in history.
+	watched := b.Clock.Now().UTC().Format("2006-01-02 15:04 MST")
+	advance(t, b, 2*time.Hour)
+	say(t, b, "carol", ".progress foo 3", 1)
+	expect(t, say(t, b, "bob", ".history foo", 3), "Recent history for foo:",
+		b.Clock.Now().UTC().Format("2006-01-02 15:04 MST")+
+			": Frieren Episode 3 (set by carol)",
+		watched+": Frieren Episode 1 \"The Journey's End\" with bob")
+	expect(t, say(t, b, "bob", ".lastwatched", 1),
+		"Last watched Frieren Episode 1 \"The Journey's End\" 2 hours ago")
+	expect(t, say(t, b, "bob", ".history bar", 1),
+		"Nothing has been watched for bar yet")
+}
+
+func TestWatchParty(t *testing.T) {
+	b := newBot(t)
+	say(t, b, "bob", ".alias foo 1", 1)
+
+	// Parties can be cancelled while waiting.
+	expect(t, say(t, b, "bob", ".watch foo", 1), "Watch party for Frieren "+
+		"Episode 1 \"The Journey's End\" is starting, type .ready when "+
+		"you're ready")
+	expect(t, say(t, b, "carol", ".watch cancel", 1), "Watch party cancelled")
+
+	// The countdown waits for everyone who joined.
+	say(t, b, "bob", ".watch foo", 1)
+	expect(t, say(t, b, "carol", ".wait", 1), "carol needs a moment (0/2 ready)")
+	expect(t, say(t, b, "bob", ".ready", 1), "bob is ready (1/2 ready)")
+	expect(t, say(t, b, "bob", ".pause", 1), "Nothing is playing here")
+	expect(t, say(t, b, "carol", ".ready", 3), "carol is ready (2/2 ready)",
+		"Everyone's ready",
+		"Starting countdown, press play when I say \"Start!\"")
+	tick(t, b)
+	advance(t, b, 5*time.Second)
+	reply(t, b, channel)
+
+	// Other countdowns can run while the episode plays.
+	say(t, b, "dave", ".countdown", 1)
+	tick(t, b)
+	for _, nick := range []string{"bob", "carol"} {
+		v, _ := b.Value("channel", channel, "anime/members/foo", nick)
+		if v != "1" {
+			t.Fatalf("expected %s's progress to be 1, got %q", nick, v)
+		}
+	}
+
+	// Pause and resume together.
+	expect(t, say(t, b, "carol", ".pause", 1), "Pausing in 3")
+	var replies []string
+	for i := 0; i < 3; i++ {
+		advance(t, b, time.Second)
+		replies = append(replies, reply(t, b, channel))
+	}
+	expect(t, replies, "2", "1", "Pause!")
+	expect(t, say(t, b, "carol", ".pause", 1), "Nothing is playing here")
+	expect(t, say(t, b, "bob", ".resume", 1), "Resuming in 3")
+	replies = nil
+	for i := 0; i < 3; i++ {
+		advance(t, b, time.Second)
+		replies = append(replies, reply(t, b, channel))
+	}
+	expect(t, replies, "2", "1", "Play!")
+
+	// Playing parties can be cancelled too.
+	expect(t, say(t, b, "bob", ".watch cancel", 1), "Watch party cancelled")
+	quiet(t, b, channel)
+	expect(t, say(t, b, "bob", ".pause", 1), "Nothing is playing here")
+	expect(t, say(t, b, "bob", ".watch cancel", 1), "Nothing is running here")
+}
+
+func TestBehind(t *testing.T) {
+	b := newBot(t)
+	say(t, b, "bob", ".alias foo 1", 1)
+	say(t, b, "bob", ".progress foo 2", 1)
+	expect(t, say(t, b, "bob", ".behind foo", 1), "Everyone's caught up on foo")
+	expect(t, say(t, b, "dave", ".myprogress foo", 1),
+		"You haven't watched any of it with the group yet")
+	expect(t, say(t, b, "carol", ".myprogress foo 1", 1),
+		"Updated your progress to 1, you're 1 behind")
+	expect(t, say(t, b, "dave", ".myprogress foo 0", 1),
+		"Updated your progress to 0, you're 2 behind")
+	expect(t, say(t, b, "bob", ".myprogress foo 3", 1),
+		"Updated your progress to 3, you're 1 ahead")
+	expect(t, say(t, b, "bob", ".behind foo", 1),
+		"Catching up to episode 2 of foo: dave (0), carol (1)")
+
+	// Aliasing a show again starts everyone over.
+	say(t, b, "bob", ".alias foo 2", 1)
+	expect(t, say(t, b, "carol", ".myprogress foo", 1),
+		"You haven't watched any of it with the group yet")
+}
+
+func TestMarathon(t *testing.T) {
+	b := newBot(t)
+	say(t, b, "bob", ".alias foo 1", 1)
+	expect(t, say(t, b, "alice", ".sync intermission 1", 1),
+		"Marathons now take 1 minute between episodes")
+
+	// Marathons stop at the last episode.
+	expect(t, say(t, b, "bob", ".watch foo 5", 2),
+		"Only 3 episodes available, watching those instead",
+		"Marathon of Frieren Episodes 1 to 3 is starting, type .ready when "+
+			"you're ready")
+	say(t, b, "bob", ".ready", 3)
+	tick(t, b)
+	advance(t, b, 5*time.Second)
+	expect(t, []string{reply(t, b, channel)}, "You're watching Frieren "+
+		"Episode 1 \"The Journey's End\"")
+
+	// The next episode follows an intermission, which the host can end early.
+	advance(t, b, time.Minute)
+	expect(t, []string{reply(t, b, channel)},
+		"Intermission, Frieren Episode 2 starts in 1 minute")
+	expect(t, say(t, b, "bob", ".start", 1),
+		"Starting countdown, press play when I say \"Start!\"")
+	tick(t, b)
+	advance(t, b, 5*time.Second)
+	expect(t, []string{reply(t, b, channel)},
+		"You're watching Frieren Episode 2")
+
+	// Cancelling stops the marathon where it is.
+	expect(t, say(t, b, "bob", ".watch cancel", 1), "Watch party cancelled")
+	quiet(t, b, channel)
+	expect(t, say(t, b, "bob", ".next foo", 1),
+		"Next up for Frieren is Episode 3 \"Killing Magic\"")
+	expect(t, say(t, b, "bob", ".progress foo 3", 1),
+		"Updated show progress, you've watched 3 episodes")
+	expect(t, say(t, b, "bob", ".watch foo 2", 1),
+		"Nothing left to watch for foo, it only has 3 episodes")
+}
+
+func TestAiring(t *testing.T) {
+	atomic.StoreInt32(&ongoingAired, 1)
+	b := newBot(t)
+	say(t, b, "bob", ".alias now 3", 1)
+	expect(t, say(t, b, "alice", ".airing 5", 1),
+		"Checking for new episodes every 5 minutes")
+
+	// The first check only remembers the latest episode.
+	advance(t, b, time.Minute)
+	quiet(t, b, channel)
+	if v, _ := b.Value("channel", channel, "anime/airing", "kitsu:3"); v != "1" {
+		t.Fatalf("expected latest episode 1, got %q", v)
+	}
+	atomic.StoreInt32(&ongoingAired, 2)
+
+	// New episodes are announced once the interval passes.
+	for i := 0; i < 4; i++ {
+		advance(t, b, time.Minute)
+	}
+	quiet(t, b, channel)
+	advance(t, b, time.Minute)
+	expect(t, []string{reply(t, b, channel)},
+		"Episode 2 of Ongoing is now out — you're 2 behind")
+
+	// Channels that opt out aren't told.
+	expect(t, say(t, b, "alice", ".airing off", 1),
+		"No longer announcing new episodes here")
+	atomic.StoreInt32(&ongoingAired, 3)
+	for i := 0; i < 5; i++ {
+		advance(t, b, time.Minute)
+	}
+	quiet(t, b, channel)
+}
@@ -0,0 +1,232 @@
+package irctest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/eventutil"
+)
+
+// Server is a minimal IRC server that accepts a single client on the loopback
+// interface. It registers the client, lets it join channels, and records
+// every line it sends so tests can assert on them.
+type Server struct {
+	listener net.Listener
+	received chan *eventutil.Event
+
+	// mu guards conn, nick, user, and capping.
+	mu      sync.Mutex
+	conn    net.Conn
+	nick    string
+	user    string
+	capping bool
+}
+
+// Caps lists the capabilities the server supports.
+var Caps = []string{"extended-join", "account-notify", "account-tag"}
+
+// NewServer starts a server listening on a random loopback port.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: listener, received: make(chan *eventutil.Event, 1024)}
+	go s.accept()
+	return s, nil
+}
+
+// Host returns the host the server is listening on.
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the server is listening on.
+func (s *Server) Port() uint16 {
+	return uint16(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// Close stops the server and disconnects its client.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// Send sends a raw line to the client.
+func (s *Server) Send(line string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return errors.New("sending line: no client connected")
+	}
+	_, err := fmt.Fprintf(s.conn, "%s\r\n", line)
+	return err
+}
+
+// Say sends a message to the client from a source, such as "nick!user@host",
+// to a target channel or the client's nickname.
+func (s *Server) Say(source string, target string, text string) error {
+	return s.Send(fmt.Sprintf(":%s PRIVMSG %s :%s", source, target, text))
+}
+
+// Nick returns the nickname the client registered with or last changed to.
+func (s *Server) Nick() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nick
+}
+
+// Expect waits for the client to send a line that matches, discarding lines
+// that don't. It returns an error if no line matches before the timeout.
+func (s *Server) Expect(timeout time.Duration,
+	match func(e *eventutil.Event) bool) (*eventutil.Event, error) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case e := <-s.received:
+			if match(e) {
+				return e, nil
+			}
+		case <-deadline:
+			return nil, errors.New("expecting line: timed out")
+		}
+	}
+}
+
+// ExpectMessage waits for the client to send a message or notice to a target,
+// and returns its text.
+func (s *Server) ExpectMessage(timeout time.Duration,
+	target string) (string, error) {
+	e, err := s.Expect(timeout, func(e *eventutil.Event) bool {
+		return (e.Type == "PRIVMSG" || e.Type == "NOTICE") && len(e.Params) > 1 &&
+			strings.EqualFold(e.Params[0], target)
+	})
+	if err != nil {
+		return "", err
+	}
+	return e.Text, nil
+}
+
+// accept handles clients one at a time until the server is closed.
+func (s *Server) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conn, s.nick, s.user, s.capping = conn, "", "", false
+		s.mu.Unlock()
+		s.handle(conn)
+	}
+}
+
+// handle reads lines from a client, records them, and answers the commands
+// needed to register and join channels.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		e, err := eventutil.Parse(scanner.Text())
+		if err != nil {
+			continue
+		}
+		s.respond(e)
+		select {
+		case s.received <- e:
+		default:
+		}
+	}
+}
+
+// respond answers a line from the client.
+func (s *Server) respond(e *eventutil.Event) {
+	param := func(i int) string {
+		if i < len(e.Params) {
+			return e.Params[i]
+		}
+		return ""
+	}
+
+	switch e.Type {
+	case "NICK":
+		// Register once both a nickname and username are known, or
+		// acknowledge a nickname change.
+		old := s.mask()
+		s.mu.Lock()
+		registered := len(s.nick) > 0 && len(s.user) > 0
+		s.nick = param(0)
+		s.mu.Unlock()
+		if registered {
+			s.Send(fmt.Sprintf(":%s NICK :%s", old, param(0)))
+		} else {
+			s.welcome()
+		}
+	case "USER":
+		s.mu.Lock()
+		s.user = param(0)
+		s.mu.Unlock()
+		s.welcome()
+	case "CAP":
+		// Hold registration while capabilities are negotiated, and accept
+		// every request.
+		switch strings.ToUpper(param(0)) {
+		case "LS":
+			s.mu.Lock()
+			s.capping = true
+			s.mu.Unlock()
+			s.Send(fmt.Sprintf(":irctest CAP * LS :%s", strings.Join(Caps, " ")))
+		case "REQ":
+			s.Send(fmt.Sprintf(":irctest CAP * ACK :%s", e.Text))
+		case "END":
+			s.mu.Lock()
+			s.capping = false
+			s.mu.Unlock()
+			s.welcome()
+		}
+	case "WHO":
+		s.Send(fmt.Sprintf(":irctest 315 %s %s :End of /WHO list.", s.Nick(),
+			param(0)))
+	case "PING":
+		s.Send(fmt.Sprintf("PONG irctest :%s", e.Text))
+	case "JOIN":
+		// Echo each join with a names list containing only the client.
+		for _, c := range strings.Split(param(0), ",") {
+			s.Send(fmt.Sprintf(":%s JOIN %s", s.mask(), c))
+			s.Send(fmt.Sprintf(":irctest 353 %s = %s :%s", s.Nick(), c, s.Nick()))
+			s.Send(fmt.Sprintf(":irctest 366 %s %s :End of /NAMES list.",
+				s.Nick(), c))
+		}
+	case "PART":
+		s.Send(fmt.Sprintf(":%s PART %s", s.mask(), param(0)))
+	}
+}
+
+// mask returns the client's hostmask as the server relays it.
+func (s *Server) mask() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("%s!%s@irctest", s.nick, s.user)
+}
+
+// welcome sends the registration replies once the client has sent both its
+// nickname and username, and finished negotiating capabilities.
+func (s *Server) welcome() {
+	s.mu.Lock()
+	nick, user, capping := s.nick, s.user, s.capping
+	s.mu.Unlock()
+	if len(nick) < 1 || len(user) < 1 || capping {
+		return
+	}
+	s.Send(fmt.Sprintf(":irctest 001 %s :Welcome to irctest", nick))
+	s.Send(fmt.Sprintf(":irctest 376 %s :End of /MOTD command.", nick))
+}
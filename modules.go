@@ -0,0 +1,11 @@
+package main
+
+// Modules are included by importing their packages, which register themselves
+// with modutil. To add a local module without editing this file, import it in
+// a new file such as modules_local.go.
+import (
+	_ "github.com/jasonpuglisi/inami-irc-bot/animecmd"
+	_ "github.com/jasonpuglisi/inami-irc-bot/execcmd"
+	_ "github.com/jasonpuglisi/inami-irc-bot/funcmd"
+	_ "github.com/jasonpuglisi/inami-irc-bot/utilcmd"
+)
@@ -0,0 +1,9 @@
+//go:build !noscripts
+
+package main
+
+// Lua scripting is included unless built with the noscripts tag, which also
+// drops the gopher-lua dependency.
+import (
+	_ "github.com/jasonpuglisi/inami-irc-bot/scriptcmd"
+)
@@ -0,0 +1,71 @@
+package modutil
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Module stores the functions a module uses to set itself up.
+type Module struct {
+	// Adds the module's functions to the command map.
+	Init func(cmdMap ircutil.CmdMap, config *configutil.Config)
+	// (Optional) Runs for each client once it's connected and registered.
+	Ready func(client *ircutil.Client)
+}
+
+// modules maps module names to registered modules.
+var modules = map[string]Module{}
+
+// modulesMu guards modules.
+var modulesMu sync.RWMutex
+
+// Register adds a module to the registry. It should be called from the
+// module's init function, so importing the module's package is enough to
+// include it. It panics if a module with the same name is already registered.
+func Register(name string, module Module) {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if _, ok := modules[name]; ok {
+		panic(fmt.Sprintf("registering module: %s already registered", name))
+	}
+	modules[name] = module
+}
+
+// Names returns the names of all registered modules in alphabetical order.
+func Names() []string {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	var names []string
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InitAll initializes every registered module in alphabetical order.
+func InitAll(cmdMap ircutil.CmdMap, config *configutil.Config) {
+	for _, name := range Names() {
+		modulesMu.RLock()
+		m := modules[name]
+		modulesMu.RUnlock()
+		m.Init(cmdMap, config)
+	}
+}
+
+// ReadyAll runs the ready function of every registered module that has one,
+// in alphabetical order.
+func ReadyAll(client *ircutil.Client) {
+	for _, name := range Names() {
+		modulesMu.RLock()
+		m := modules[name]
+		modulesMu.RUnlock()
+		if m.Ready != nil {
+			m.Ready(client)
+		}
+	}
+}
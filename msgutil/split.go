@@ -0,0 +1,110 @@
+package msgutil
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// lineLimit is the maximum length of an IRC line, including the trailing CRLF.
+const lineLimit = 512
+
+// hostLimit is the maximum length of a hostname, used when estimating the
+// length of the bot's hostmask as the server will relay it.
+const hostLimit = 63
+
+// MaxLines is the maximum number of lines a single message can be split into.
+// Anything beyond it is cut off and marked with an ellipsis. Default: 0 (no
+// limit)
+var MaxLines int
+
+// SendResponse sends a response to a target the same way ircutil does, but
+// splits text that would otherwise be truncated by the server.
+func SendResponse(client *ircutil.Client, source string, target string,
+	text string) {
+	recipient := target
+	if !ircutil.IsChannel(target) {
+		recipient = ircutil.GetNick(source)
+	}
+	for _, line := range Split(text, Room(client, "PRIVMSG", recipient)) {
+		ircutil.SendResponse(client, source, target, line)
+	}
+}
+
+// SendPrivmsg sends a message to a target, split to fit the line limit.
+func SendPrivmsg(client *ircutil.Client, target string, text string) {
+	for _, line := range Split(text, Room(client, "PRIVMSG", target)) {
+		ircutil.SendPrivmsg(client, target, line)
+	}
+}
+
+// SendNotice sends a notice to a target, split to fit the line limit.
+func SendNotice(client *ircutil.Client, target string, text string) {
+	for _, line := range Split(text, Room(client, "NOTICE", target)) {
+		ircutil.SendNotice(client, target, line)
+	}
+}
+
+// Room returns the number of bytes available for text in a message of the
+// given type sent to a recipient, once the server has prefixed it with the
+// bot's hostmask.
+func Room(client *ircutil.Client, kind string, recipient string) int {
+	// The server may prepend a tilde to unidentified usernames, and we can't
+	// know our own host, so assume the longest possible one.
+	user := ""
+	if client.User != nil {
+		user = client.User.User
+	}
+	prefix := fmt.Sprintf(":%s!~%s@%s %s %s :", client.Nick, user,
+		strings.Repeat("x", hostLimit), kind, recipient)
+	return lineLimit - len(prefix) - len("\r\n")
+}
+
+// Split breaks text into lines of at most size bytes. Lines are broken on
+// spaces where possible, and never in the middle of a UTF-8 sequence.
+func Split(text string, size int) []string {
+	// Make sure we always make progress, even with a ridiculous size.
+	if size < utf8.UTFMax {
+		size = utf8.UTFMax
+	}
+
+	var lines []string
+	for len(text) > size {
+		// Find the last rune boundary that fits, then prefer the last space
+		// before it. A boundary is never more than a rune's length back, so if
+		// there isn't one the text isn't valid UTF-8, and it's cut at the size.
+		cut := size
+		for cut > size-utf8.UTFMax && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if !utf8.RuneStart(text[cut]) {
+			cut = size
+		}
+		if i := strings.LastIndexByte(text[:cut+1], ' '); i > 0 {
+			lines = append(lines, text[:i])
+			text = strings.TrimLeft(text[i:], " ")
+		} else {
+			lines = append(lines, text[:cut])
+			text = text[cut:]
+		}
+	}
+	if len(text) > 0 || len(lines) == 0 {
+		lines = append(lines, text)
+	}
+
+	// Cut off lines beyond the maximum, marking the last one as continued.
+	if MaxLines > 0 && len(lines) > MaxLines {
+		lines = lines[:MaxLines]
+		last := lines[MaxLines-1]
+		const ellipsis = "…"
+		for len(last)+len(ellipsis) > size {
+			_, n := utf8.DecodeLastRuneInString(last)
+			last = last[:len(last)-n]
+		}
+		lines[MaxLines-1] = strings.TrimRight(last, " ") + ellipsis
+	}
+
+	return lines
+}
@@ -0,0 +1,70 @@
+package msgutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []string
+	}{
+		{"short", "hello", 10, []string{"hello"}},
+		{"empty", "", 10, []string{""}},
+		{"spaces", "one two three four", 9, []string{"one two", "three", "four"}},
+		{"long word", "abcdefghijklmnop", 6, []string{"abcdef", "ghijkl", "mnop"}},
+		{"multibyte", "ééééé", 4, []string{"éé", "éé", "é"}},
+		{"invalid", strings.Repeat("\xa9", 10), 4,
+			[]string{"\xa9\xa9\xa9\xa9", "\xa9\xa9\xa9\xa9", "\xa9\xa9"}},
+	}
+	for _, tt := range tests {
+		got := Split(tt.text, tt.size)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: Split(%q, %d) = %q, want %q", tt.name, tt.text, tt.size,
+				got, tt.want)
+		}
+	}
+}
+
+func TestSplitInvalidUTF8(t *testing.T) {
+	// Long runs of Latin-1 bytes have no rune boundaries at all, and mixing
+	// them with valid text shouldn't lose or duplicate any bytes.
+	for _, text := range []string{
+		strings.Repeat("\xa9", 50),
+		"caf\xe9" + strings.Repeat("\x80\xbf", 40) + "ok",
+		strings.Repeat("é\xa9", 30),
+	} {
+		lines := Split(text, 10)
+		if strings.Join(lines, "") != text {
+			t.Errorf("Split(%q, 10) changed the text: %q", text, lines)
+		}
+		for _, l := range lines {
+			if len(l) > 10 || len(l) < 1 {
+				t.Errorf("Split(%q, 10) gave a line of %d bytes", text, len(l))
+			}
+		}
+	}
+}
+
+func TestSplitKeepsRunes(t *testing.T) {
+	text := strings.Repeat("日本語", 20)
+	for _, l := range Split(text, 10) {
+		if !utf8.ValidString(l) {
+			t.Errorf("line %q isn't valid UTF-8", l)
+		}
+	}
+}
+
+func TestSplitMaxLines(t *testing.T) {
+	defer func(n int) { MaxLines = n }(MaxLines)
+	MaxLines = 2
+	got := Split("aaaa bbbb cccc dddd eeee", 9)
+	want := []string{"aaaa bbbb", "cccc d…"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Split with MaxLines = %q, want %q", got, want)
+	}
+}
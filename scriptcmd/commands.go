@@ -0,0 +1,56 @@
+package scriptcmd
+
+import (
+	"fmt"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/modutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+func init() {
+	modutil.Register("scriptcmd", modutil.Module{
+		Init: func(cmdMap ircutil.CmdMap, config *configutil.Config) {
+			Init(cmdMap, config.Scripts, config.Commands)
+		},
+	})
+}
+
+// Init loads the scripts in a directory and adds scriptcmd's functions to the
+// command map, along with every script function used by commands or
+// registered by scripts. Scripts that fail to load are skipped.
+func Init(cmdMap ircutil.CmdMap, dir string, commands []ircutil.Command) {
+	ircutil.AddCommand(cmdMap, "inami/scriptcmd.Reload", Reload)
+	loaded, errs := load(dir)
+	for _, err := range errs {
+		fmt.Printf("Error loading script.\n%s\n", err)
+	}
+	if loaded > 0 {
+		fmt.Printf("Loaded %d scripts from %s.\n", loaded, dir)
+	}
+	addFunctions(cmdMap, commands)
+}
+
+// Reload loads every script again, picking up changes without a restart.
+// Only functions added at startup can be used by commands, which covers every
+// script function key in the config. Function key: inami/scriptcmd.Reload
+func Reload(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Reload scripts and log any errors.
+	scriptsMu.RLock()
+	dir := scriptDir
+	scriptsMu.RUnlock()
+	loaded, errs := load(dir)
+	for _, err := range errs {
+		ircutil.Log(client, err.Error())
+	}
+
+	// Send response with number of scripts loaded and failed.
+	failed := ""
+	if len(errs) > 0 {
+		failed = fmt.Sprintf(", %d failed (see log)", len(errs))
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Reloaded %d scripts%s", loaded, failed))
+}
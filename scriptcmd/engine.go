@@ -0,0 +1,348 @@
+package scriptcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// runTimeout is the maximum time a script can run for when loaded or called.
+const runTimeout = 10 * time.Second
+
+// fetchLimit is the maximum number of bytes read from an HTTP response.
+const fetchLimit = 1 << 20
+
+// fetchClient is used for HTTP requests made by scripts. It doesn't use a
+// proxy and refuses to connect to local addresses, including after redirects.
+var fetchClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 10 * time.Second,
+			Control: checkDial}).DialContext,
+	},
+}
+
+// script stores a loaded script and the functions it registered.
+type script struct {
+	// mu guards state, which can only be used by one goroutine at a time.
+	mu        sync.Mutex
+	state     *lua.LState
+	functions map[string]*lua.LFunction
+}
+
+// scripts maps script names, which are file names without their extension, to
+// loaded scripts.
+var scripts = map[string]*script{}
+
+// scriptsMu guards scripts.
+var scriptsMu sync.RWMutex
+
+// scriptDir is the directory scripts are loaded from. It's guarded by
+// scriptsMu.
+var scriptDir string
+
+// load loads every Lua script in a directory, replacing any loaded before. It
+// returns the number of scripts loaded and any errors. The command map isn't
+// touched, since functions look up their script on every call.
+func load(dir string) (int, []error) {
+	// Find scripts in the directory.
+	scriptsMu.Lock()
+	scriptDir = dir
+	scriptsMu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.lua"))
+	if err != nil {
+		return 0, []error{err}
+	}
+
+	// Load each script, keeping going if one fails.
+	loaded := map[string]*script{}
+	var errs []error
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".lua")
+		s, err := newScript(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("loading script %s: %s", name, err))
+			continue
+		}
+		loaded[name] = s
+	}
+
+	// Swap in the new scripts and close the old ones once they're idle.
+	scriptsMu.Lock()
+	old := scripts
+	scripts = loaded
+	scriptsMu.Unlock()
+	for _, s := range old {
+		s.mu.Lock()
+		s.state.Close()
+		s.mu.Unlock()
+	}
+	return len(loaded), errs
+}
+
+// addFunctions adds a function to the command map for every script function
+// key used by a command or registered by a loaded script. It should only be
+// called during initialization, before clients read the command map.
+func addFunctions(cmdMap ircutil.CmdMap, commands []ircutil.Command) {
+	keys := map[string]bool{}
+	for _, c := range commands {
+		if strings.HasPrefix(c.Function, "script/") {
+			keys[c.Function] = true
+		}
+	}
+	scriptsMu.RLock()
+	for name, s := range scripts {
+		for f := range s.functions {
+			keys[fmt.Sprintf("script/%s.%s", name, f)] = true
+		}
+	}
+	scriptsMu.RUnlock()
+
+	// Split each key into its script and function names at the last dot.
+	for key := range keys {
+		i := strings.LastIndex(key, ".")
+		if i <= len("script/") {
+			continue
+		}
+		ircutil.AddCommand(cmdMap, key, function(key[len("script/"):i], key[i+1:]))
+	}
+}
+
+// newScript creates a sandboxed Lua state, runs a script in it, and collects
+// the functions it registers.
+func newScript(path string) (*script, error) {
+	// Open only libraries that can't touch the file system or the process.
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		fn   lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.fn))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring",
+		"require", "module"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	// Expose the API used to register functions and fetch URLs.
+	s := &script{state: L, functions: map[string]*lua.LFunction{}}
+	api := L.NewTable()
+	L.SetField(api, "register", L.NewFunction(func(L *lua.LState) int {
+		s.functions[L.CheckString(1)] = L.CheckFunction(2)
+		return 0
+	}))
+	L.SetField(api, "fetch", L.NewFunction(fetch))
+	L.SetGlobal("inami", api)
+
+	// Run the script with a time limit.
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+	err := L.DoFile(path)
+	if err != nil {
+		L.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// function returns a command function that calls a function registered by a
+// script, looking it up on every call so reloaded scripts take effect.
+func function(name string, f string) func(*ircutil.Client, *ircutil.Command,
+	*ircutil.Message) {
+	return func(client *ircutil.Client, command *ircutil.Command,
+		message *ircutil.Message) {
+		// Find the script and function.
+		scriptsMu.RLock()
+		s, ok := scripts[name]
+		scriptsMu.RUnlock()
+		if !ok {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"That script isn't loaded")
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		fn, ok := s.functions[f]
+		if !ok {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"That script function isn't loaded")
+			return
+		}
+
+		// Call the function with a time limit and a table describing the
+		// message.
+		L := s.state
+		ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+		defer cancel()
+		L.SetContext(ctx)
+		defer L.RemoveContext()
+		err := L.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true},
+			messageTable(L, name, client, command, message))
+		if err != nil {
+			ircutil.Log(client, fmt.Sprintf("Error in script/%s.%s: %s", name, f,
+				err))
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Error running command, try again later")
+		}
+	}
+}
+
+// messageTable creates the table passed to script functions. It holds the
+// message's source, nick, target, and arguments, and functions to respond and
+// use the script's persistent data.
+func messageTable(L *lua.LState, name string, client *ircutil.Client,
+	command *ircutil.Command, message *ircutil.Message) *lua.LTable {
+	t := L.NewTable()
+	L.SetField(t, "source", lua.LString(message.Source))
+	L.SetField(t, "nick", lua.LString(ircutil.GetNick(message.Source)))
+	L.SetField(t, "target", lua.LString(message.Target))
+
+	// Add positional and named arguments.
+	args := L.NewTable()
+	for _, a := range message.Args {
+		args.Append(lua.LString(a))
+	}
+	L.SetField(t, "args", args)
+	named := L.NewTable()
+	for k, v := range configutil.GetArgs(command, message) {
+		L.SetField(named, k, lua.LString(v))
+	}
+	L.SetField(t, "named", named)
+
+	// Add functions bound to this message.
+	L.SetField(t, "send_response", L.NewFunction(func(L *lua.LState) int {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			L.CheckString(1))
+		return 0
+	}))
+	L.SetField(t, "get_value", L.NewFunction(func(L *lua.LState) int {
+		keys := dataKeys(L, name, message, L.CheckString(1), L.OptString(2, ""))
+		value, err := configutil.GetValue(client, keys)
+		if err != nil {
+			L.RaiseError("%s", err)
+		}
+		L.Push(lua.LString(value))
+		return 1
+	}))
+	L.SetField(t, "set_value", L.NewFunction(func(L *lua.LState) int {
+		keys := dataKeys(L, name, message, L.CheckString(1), L.OptString(3, ""))
+		err := configutil.SetValue(client, keys, L.CheckString(2))
+		if err != nil {
+			L.RaiseError("%s", err)
+		}
+		return 0
+	}))
+	return t
+}
+
+// dataKeys builds the keys for a data function in the script's own group. The
+// scope is "channel" for the message's channel or "user" for its sender, and
+// defaults to the channel when the message was sent to one.
+func dataKeys(L *lua.LState, name string, message *ircutil.Message,
+	key string, scope string) []string {
+	if scope == "" {
+		scope = "user"
+		if ircutil.IsChannel(message.Target) {
+			scope = "channel"
+		}
+	}
+	owner := ""
+	switch scope {
+	case "user":
+		owner = ircutil.GetNick(message.Source)
+	case "channel":
+		if !ircutil.IsChannel(message.Target) {
+			L.RaiseError("channel data used outside a channel")
+		}
+		owner = message.Target
+	default:
+		L.RaiseError("scope must be user or channel")
+	}
+	return []string{scope, owner, "scripts/" + name, key}
+}
+
+// fetch gets a URL over HTTP or HTTPS and returns its body and status code, or
+// nil and an error message.
+func fetch(L *lua.LState) int {
+	body, status, err := fetchURL(L.Context(), L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LString(body))
+	L.Push(lua.LNumber(status))
+	return 2
+}
+
+// errLocalAddress is returned when a script fetches a URL on a local or private
+// address.
+var errLocalAddress = errors.New("fetching: local addresses aren't allowed")
+
+// checkDial refuses connections to loopback, link-local, private, and
+// unspecified addresses. It runs after name resolution, so host names can't be
+// used to reach them.
+func checkDial(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return errLocalAddress
+	}
+	return nil
+}
+
+// fetchURL gets a URL over HTTP or HTTPS, cancelling the request if the
+// context is done, and returns its body and status code.
+func fetchURL(ctx context.Context, url string) (string, int, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return "", 0, errors.New("fetching: url must use http or https")
+	}
+
+	// Send request.
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	// Read a limited amount of the body.
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, fetchLimit))
+	if err != nil {
+		return "", 0, err
+	}
+	return string(body), resp.StatusCode, nil
+}
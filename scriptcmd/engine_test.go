@@ -0,0 +1,46 @@
+package scriptcmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckDial(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"93.184.216.34:80", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:443", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	}
+	for _, tt := range tests {
+		if got := checkDial("tcp", tt.address, nil) == nil; got != tt.want {
+			t.Errorf("checkDial(%q) allowed = %t, want %t", tt.address, got,
+				tt.want)
+		}
+	}
+}
+
+func TestFetchURLRefusesLocal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+	body, _, err := fetchURL(context.Background(), server.URL)
+	if err == nil {
+		t.Fatalf("fetchURL(%q) = %q, want error", server.URL, body)
+	}
+}
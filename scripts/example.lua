@@ -0,0 +1,17 @@
+-- Example script. Functions registered here can be used in commands with the
+-- function key "script/example.<name>".
+
+-- Hello greets the sender, or a name if given, and counts how many times the
+-- sender has asked.
+inami.register("Hello", function(msg)
+  local name = msg.named.name
+  if name == nil or name == "" then
+    name = msg.nick
+  end
+
+  local count = tonumber(msg.get_value("count", "user")) or 0
+  count = count + 1
+  msg.set_value("count", tostring(count), "user")
+
+  msg.send_response(string.format("Hello, %s! (%d)", name, count))
+end)
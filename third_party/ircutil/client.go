@@ -0,0 +1,270 @@
+package ircutil
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// EstablishConnection connects a client to its server, registers it, and
+// handles lines from the server in the background until the connection
+// closes.
+func EstablishConnection(client *Client) error {
+	if client.Server == nil || client.User == nil {
+		return errors.New("establishing connection: missing server or user")
+	}
+
+	// Connect, using TLS for secure servers.
+	address := net.JoinHostPort(client.Server.Host,
+		strconv.Itoa(int(client.Server.Port)))
+	var conn net.Conn
+	var err error
+	if client.Server.Secure {
+		conn, err = tls.Dial("tcp", address,
+			&tls.Config{ServerName: client.Server.Host})
+	} else {
+		conn, err = net.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	client.connMu.Lock()
+	client.conn = conn
+	client.connMu.Unlock()
+	if len(client.Nick) < 1 {
+		client.Nick = client.User.Nick
+	}
+
+	// Register, asking for capabilities first so the server holds
+	// registration until negotiation ends.
+	if len(client.Caps) > 0 {
+		client.capsMu.Lock()
+		client.capping = true
+		client.capsMu.Unlock()
+		SendRaw(client, "CAP LS 302")
+	}
+	if len(client.Authentication.ServerPassword) > 0 {
+		SendRaw(client, "PASS "+client.Authentication.ServerPassword)
+	}
+	SendNick(client, client.Nick)
+	SendRaw(client, fmt.Sprintf("USER %s 0 * :%s", client.User.User,
+		client.User.Real))
+
+	go listen(client, conn)
+	return nil
+}
+
+// listen reads lines from a connection and handles them until it closes.
+func listen(client *Client, conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 1 {
+			continue
+		}
+		if client.Debug {
+			Log(client, "<- "+line)
+		}
+		if client.Receive != nil && !client.Receive(client, line) {
+			continue
+		}
+		handle(client, parseLine(line))
+	}
+	conn.Close()
+	if client.Done != nil {
+		client.Done <- true
+	}
+}
+
+// line stores the parts of a line received from a server.
+type line struct {
+	source  string
+	command string
+	params  []string
+}
+
+// param returns a parameter of a line, or an empty string if it's missing.
+func (l line) param(i int) string {
+	if i < len(l.params) {
+		return l.params[i]
+	}
+	return ""
+}
+
+// parseLine splits a line into its source, command, and parameters, skipping
+// message tags.
+func parseLine(raw string) line {
+	var l line
+	if strings.HasPrefix(raw, "@") {
+		if i := strings.IndexByte(raw, ' '); i >= 0 {
+			raw = strings.TrimLeft(raw[i:], " ")
+		} else {
+			return l
+		}
+	}
+	if strings.HasPrefix(raw, ":") {
+		i := strings.IndexByte(raw, ' ')
+		if i < 0 {
+			return l
+		}
+		l.source, raw = raw[1:i], strings.TrimLeft(raw[i:], " ")
+	}
+	for len(raw) > 0 {
+		if raw[0] == ':' {
+			l.params = append(l.params, raw[1:])
+			break
+		}
+		i := strings.IndexByte(raw, ' ')
+		if i < 0 {
+			l.params = append(l.params, raw)
+			break
+		}
+		l.params = append(l.params, raw[:i])
+		raw = strings.TrimLeft(raw[i:], " ")
+	}
+	if len(l.params) > 0 {
+		l.command, l.params = strings.ToUpper(l.params[0]), l.params[1:]
+	}
+	return l
+}
+
+// handle responds to a line from the server.
+func handle(client *Client, l line) {
+	switch l.command {
+	case "PING":
+		SendRaw(client, "PONG :"+l.param(0))
+	case "CAP":
+		handleCap(client, l)
+	case "001":
+		// Registration is done, so stop waiting on capabilities and use the
+		// nickname the server confirmed.
+		client.capsMu.Lock()
+		client.capping = false
+		client.capsMu.Unlock()
+		client.Nick = l.param(0)
+		if client.Ready != nil {
+			go client.Ready(client)
+		}
+	case "433":
+		// Pick another nickname if ours is taken while registering.
+		if l.param(0) == "*" {
+			client.Nick += "_"
+			SendNick(client, client.Nick)
+		}
+	case "NICK":
+		if strings.EqualFold(GetNick(l.source), client.Nick) {
+			client.Nick = l.param(0)
+		}
+	case "PRIVMSG":
+		runCommand(client, l.source, l.param(0), l.param(1))
+	}
+}
+
+// handleCap requests the wanted capabilities the server supports, and ends
+// negotiation once the server answers.
+func handleCap(client *Client, l line) {
+	switch strings.ToUpper(l.param(1)) {
+	case "LS":
+		// Collect supported capabilities, which can span several lines, then
+		// request the wanted ones.
+		client.capsMu.Lock()
+		if client.offered == nil {
+			client.offered = map[string]bool{}
+		}
+		for _, c := range strings.Fields(l.param(len(l.params) - 1)) {
+			client.offered[strings.SplitN(c, "=", 2)[0]] = true
+		}
+		var wanted []string
+		for _, c := range client.Caps {
+			if client.offered[c] {
+				wanted = append(wanted, c)
+			}
+		}
+		client.capsMu.Unlock()
+		if l.param(2) == "*" {
+			return
+		}
+		if len(wanted) < 1 {
+			endCap(client)
+			return
+		}
+		SendRaw(client, "CAP REQ :"+strings.Join(wanted, " "))
+	case "ACK", "NAK":
+		endCap(client)
+	}
+}
+
+// endCap ends capability negotiation if it's still in progress.
+func endCap(client *Client) {
+	client.capsMu.Lock()
+	capping := client.capping
+	client.capping = false
+	client.capsMu.Unlock()
+	if capping {
+		SendRaw(client, "CAP END")
+	}
+}
+
+// runCommand runs the first command whose trigger starts a message, if the
+// command can be used by the message's source in its target.
+func runCommand(client *Client, source, target, text string) {
+	words := strings.Fields(text)
+	if len(words) < 1 {
+		return
+	}
+	scope := "direct"
+	if IsChannel(target) {
+		scope = "channel"
+	}
+	for i := range client.Commands {
+		command := &client.Commands[i]
+		if !inScope(command, scope) ||
+			command.Settings.Admin && !isAdmin(client, source) {
+			continue
+		}
+		for _, t := range command.Triggers {
+			trigger := command.Settings.Symbol +
+				strings.Replace(t, "%NICK%", client.Nick, -1)
+			if words[0] != trigger && (command.Settings.CaseSensitive ||
+				!strings.EqualFold(words[0], trigger)) {
+				continue
+			}
+			fn, ok := client.CmdMap[command.Function]
+			if !ok {
+				return
+			}
+			go fn(client, command, &Message{Source: source, Target: target,
+				Args: words[1:]})
+			return
+		}
+	}
+}
+
+// inScope checks whether a command can be used in a scope.
+func inScope(command *Command, scope string) bool {
+	for _, s := range command.Settings.Scope {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdmin checks whether a source's nickname is in a client's admin list.
+func isAdmin(client *Client, source string) bool {
+	nick := GetNick(source)
+	for _, a := range client.Admins {
+		if a == nick {
+			return true
+		}
+	}
+	return false
+}
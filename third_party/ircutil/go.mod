@@ -0,0 +1,3 @@
+module github.com/jasonpuglisi/ircutil
+
+go 1.21
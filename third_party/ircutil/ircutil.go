@@ -0,0 +1,134 @@
+// Package ircutil connects to IRC servers and runs commands from messages.
+// This is a fork of github.com/jasonpuglisi/ircutil for inami-irc-bot, see
+// README.md for how it differs from upstream.
+package ircutil
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Settings stores how a command is triggered and who can use it.
+type Settings struct {
+	// Whether triggers must match case exactly.
+	CaseSensitive bool `json:"caseSensitive"`
+	// Prefix typed before a trigger, such as "!".
+	Symbol string `json:"symbol"`
+	// Where the command can be used, "channel" and/or "direct".
+	Scope []string `json:"scope"`
+	// Whether only nicknames in a client's admin list can use the command.
+	Admin bool `json:"admin"`
+}
+
+// Server stores the address of an IRC server.
+type Server struct {
+	ID     string `json:"id"`
+	Host   string `json:"host"`
+	Port   uint16 `json:"port"`
+	Secure bool   `json:"secure"`
+}
+
+// User stores the identity a client registers with.
+type User struct {
+	ID   string `json:"id"`
+	Nick string `json:"nick"`
+	User string `json:"user"`
+	Real string `json:"real"`
+}
+
+// Authentication stores passwords used when connecting.
+type Authentication struct {
+	ServerPassword string `json:"serverPassword"`
+	Nickserv       string `json:"nickserv"`
+}
+
+// Command stores a command's triggers and the key of the function it runs.
+type Command struct {
+	Triggers  []string `json:"triggers"`
+	Function  string   `json:"function"`
+	Arguments string   `json:"arguments"`
+	Settings  Settings `json:"settings"`
+}
+
+// Message stores the source, target, and arguments of a command message.
+type Message struct {
+	Source string
+	Target string
+	Args   []string
+}
+
+// Data stores persistent data by client prefix, scope, owner, group, and key.
+type Data map[string]map[string]map[string]map[string]map[string]string
+
+// CmdMap maps function keys to command functions.
+type CmdMap map[string]func(*Client, *Command, *Message)
+
+// Client stores a connection to a server and everything it needs to run
+// commands.
+type Client struct {
+	ServerID       string         `json:"serverId"`
+	UserID         string         `json:"userId"`
+	Channels       []string       `json:"channels"`
+	Modes          string         `json:"modes"`
+	Admins         []string       `json:"admins"`
+	Authentication Authentication `json:"authentication"`
+	Server         *Server        `json:"-"`
+	User           *User          `json:"-"`
+	Data           Data           `json:"-"`
+	DataFile       *string        `json:"-"`
+	Commands       []Command      `json:"-"`
+	CmdMap         CmdMap         `json:"-"`
+	Debug          bool           `json:"-"`
+	// Ready is called after the client registers with the server.
+	Ready func(*Client) `json:"-"`
+	// Receive is called with every line received from the server, before the
+	// client handles it. Lines it returns false for aren't handled. Added in
+	// this fork.
+	Receive func(*Client, string) bool `json:"-"`
+	// Caps lists IRCv3 capabilities to request when the server supports them.
+	// Added in this fork.
+	Caps []string `json:"-"`
+	// Done receives a value when the connection closes.
+	Done chan bool `json:"-"`
+	Nick string    `json:"-"`
+
+	conn    net.Conn
+	connMu  sync.Mutex
+	capsMu  sync.Mutex
+	capping bool
+	offered map[string]bool
+}
+
+// InitCommands returns an empty command map.
+func InitCommands() CmdMap {
+	return CmdMap{}
+}
+
+// AddCommand adds a function to a command map under a key.
+func AddCommand(cmdMap CmdMap, key string,
+	fn func(*Client, *Command, *Message)) {
+	cmdMap[key] = fn
+}
+
+// GetClientPrefix returns the prefix identifying a client in logs and data.
+func GetClientPrefix(client *Client) string {
+	return fmt.Sprintf("%s/%s", client.ServerID, client.UserID)
+}
+
+// Log prints a message prefixed with its client.
+func Log(client *Client, message string) {
+	log.Printf("[%s] %s", GetClientPrefix(client), message)
+}
+
+// IsChannel checks whether a target is a channel.
+func IsChannel(target string) bool {
+	return len(target) > 0 && strings.IndexByte("#&+!", target[0]) >= 0
+}
+
+// GetNick returns the nickname of a hostmask such as "nick!user@host".
+func GetNick(source string) string {
+	return strings.SplitN(source, "!", 2)[0]
+}
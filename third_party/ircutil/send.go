@@ -0,0 +1,71 @@
+package ircutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SendRaw sends a line to a client's server as-is. Line breaks are removed so
+// a line can't smuggle in another command.
+func SendRaw(client *Client, message string) {
+	message = strings.NewReplacer("\r", "", "\n", " ").Replace(message)
+	client.connMu.Lock()
+	defer client.connMu.Unlock()
+	if client.conn == nil {
+		return
+	}
+	if client.Debug {
+		Log(client, "-> "+message)
+	}
+	fmt.Fprintf(client.conn, "%s\r\n", message)
+}
+
+// SendPrivmsg sends a message to a target.
+func SendPrivmsg(client *Client, target string, message string) {
+	SendRaw(client, fmt.Sprintf("PRIVMSG %s :%s", target, message))
+}
+
+// SendNotice sends a notice to a target.
+func SendNotice(client *Client, target string, message string) {
+	SendRaw(client, fmt.Sprintf("NOTICE %s :%s", target, message))
+}
+
+// SendResponse replies to a message, in its channel or directly to its
+// source.
+func SendResponse(client *Client, source string, target string,
+	message string) {
+	if IsChannel(target) {
+		SendPrivmsg(client, target, message)
+	} else {
+		SendPrivmsg(client, GetNick(source), message)
+	}
+}
+
+// SendJoin joins a channel, with a key if it needs one.
+func SendJoin(client *Client, channel string, key string) {
+	if len(key) > 0 {
+		SendRaw(client, fmt.Sprintf("JOIN %s %s", channel, key))
+		return
+	}
+	SendRaw(client, "JOIN "+channel)
+}
+
+// SendPart leaves a channel with a message.
+func SendPart(client *Client, channel string, message string) {
+	SendRaw(client, fmt.Sprintf("PART %s :%s", channel, message))
+}
+
+// SendNick changes a client's nickname.
+func SendNick(client *Client, nick string) {
+	SendRaw(client, "NICK "+nick)
+}
+
+// SendModeUser sets modes on a client's own nickname.
+func SendModeUser(client *Client, modes string) {
+	SendRaw(client, fmt.Sprintf("MODE %s %s", client.Nick, modes))
+}
+
+// SendNickservPass identifies a client with NickServ.
+func SendNickservPass(client *Client, password string) {
+	SendPrivmsg(client, "NickServ", "IDENTIFY "+password)
+}
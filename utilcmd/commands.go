@@ -2,11 +2,30 @@ package utilcmd
 
 import (
 	"fmt"
-	"strings"
+	"sync"
 
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/modutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
 	"github.com/jasonpuglisi/ircutil"
 )
 
+// subscribeOnce makes sure events are only subscribed to once, even if the
+// module is initialized again.
+var subscribeOnce sync.Once
+
+func init() {
+	modutil.Register("utilcmd", modutil.Module{
+		Init: func(cmdMap ircutil.CmdMap, config *configutil.Config) {
+			Init(cmdMap)
+			subscribeOnce.Do(func() {
+				subscribeMacros()
+				subscribePatterns()
+			})
+		},
+	})
+}
+
 // Init adds utilcmd's functions to the command map.
 func Init(cmdMap ircutil.CmdMap) {
 	ircutil.AddCommand(cmdMap, "inami/utilcmd.Nick", Nick)
@@ -17,52 +36,58 @@ func Init(cmdMap ircutil.CmdMap) {
 	ircutil.AddCommand(cmdMap, "inami/utilcmd.Do", Do)
 	ircutil.AddCommand(cmdMap, "inami/utilcmd.GetProfileItem", GetProfileItem)
 	ircutil.AddCommand(cmdMap, "inami/utilcmd.SetProfileItem", SetProfileItem)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Help", Help)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Grant", Grant)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Revoke", Revoke)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Roles", Roles)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Ignore", Ignore)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Unignore", Unignore)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Ignores", Ignores)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Macro", Macro)
+	ircutil.AddCommand(cmdMap, "inami/utilcmd.Macros", Macros)
+	ircutil.AddCommand(cmdMap, macroKey, RunMacro)
 }
 
 // Nick updates a nickname. Function key: inami/utilcmd.Nick
 func Nick(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	ircutil.SendNick(client, message.Args[0])
+	args := configutil.GetArgs(command, message)
+	ircutil.SendNick(client, args["nickname"])
 }
 
 // Join attahces to a channel with an optional password.
 // Function key: inami/utilcmd.Join
 func Join(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	pass := ""
-	if len(message.Args) > 1 {
-		pass = message.Args[1]
-	}
-	ircutil.SendJoin(client, message.Args[0], pass)
+	args := configutil.GetArgs(command, message)
+	ircutil.SendJoin(client, args["channel"], args["password"])
 }
 
 // Part detaches from a channel. Function key: inami/utilcmd.Part
 func Part(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	msg := ""
-	if len(message.Args) > 1 {
-		msg = strings.Join(message.Args[1:], " ")
-	}
-	ircutil.SendPart(client, message.Args[0], msg)
+	args := configutil.GetArgs(command, message)
+	ircutil.SendPart(client, args["channel"], args["message"])
 }
 
 // Say sends a message to a target. Function key: inami/utilcmd.Say
 func Say(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	ircutil.SendPrivmsg(client, message.Args[0], strings.Join(message.Args[1:],
-		" "))
+	args := configutil.GetArgs(command, message)
+	msgutil.SendPrivmsg(client, args["target"], args["message"])
 }
 
 // Notify sends a notice to a target. Function key: inami/utilcmd.Notify
 func Notify(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	ircutil.SendNotice(client, message.Args[0], strings.Join(message.Args[1:],
-		" "))
+	args := configutil.GetArgs(command, message)
+	msgutil.SendNotice(client, args["target"], args["message"])
 }
 
 // Do performs an action at a target. Function key: inami/utilcmd.Do
 func Do(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
-	ircutil.SendPrivmsg(client, message.Args[0], fmt.Sprintf("\x01ACTION %s\x01",
-		strings.Join(message.Args[1:], " ")))
+	args := configutil.GetArgs(command, message)
+	ircutil.SendPrivmsg(client, args["target"], fmt.Sprintf("\x01ACTION %s\x01",
+		args["action"]))
 }
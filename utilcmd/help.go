@@ -0,0 +1,113 @@
+package utilcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Help lists available commands, or shows usage for a single command.
+// Function key: inami/utilcmd.Help
+func Help(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Send details for a specific command if one was requested.
+	if name := configutil.GetArgs(command, message)["command"]; len(name) > 0 {
+		helpCommand(client, message, name)
+		return
+	}
+
+	// Collect the primary trigger of every command usable here.
+	var triggers []string
+	for i := range client.Commands {
+		c := &client.Commands[i]
+		if len(c.Triggers) > 0 && cmdutil.Available(client, c, message) {
+			triggers = append(triggers,
+				cmdutil.FormatTrigger(client, c, c.Triggers[0]))
+		}
+	}
+
+	// Send response with available commands and macros.
+	if len(triggers) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"No commands available here")
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Commands: %s", strings.Join(triggers, ", ")))
+	if macros := macroTriggers(client, message.Target); len(macros) > 0 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Macros: %s", strings.Join(macros, ", ")))
+	}
+}
+
+// helpCommand sends the usage, aliases, and description of a command matching
+// a trigger.
+func helpCommand(client *ircutil.Client, message *ircutil.Message,
+	trigger string) {
+	// Find the first available command with a matching trigger, allowing the
+	// symbol to be included.
+	for i := range client.Commands {
+		c := &client.Commands[i]
+		if !cmdutil.Available(client, c, message) {
+			continue
+		}
+		for _, t := range c.Triggers {
+			if !matchTrigger(client, c, t, trigger) {
+				continue
+			}
+
+			// Send response with usage, aliases, and description.
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("Usage: %s", cmdutil.Usage(client, c)))
+			if len(c.Triggers) > 1 {
+				var aliases []string
+				for _, a := range c.Triggers[1:] {
+					aliases = append(aliases, cmdutil.FormatTrigger(client, c, a))
+				}
+				msgutil.SendResponse(client, message.Source, message.Target,
+					fmt.Sprintf("Aliases: %s", strings.Join(aliases, ", ")))
+			}
+			if d := configutil.GetCommandInfo(c).Description; len(d) > 0 {
+				msgutil.SendResponse(client, message.Source, message.Target, d)
+			}
+			return
+		}
+	}
+
+	// Send response with a macro's expansion if one is defined here, allowing
+	// the symbol to be left out.
+	if manager := macroManager(client); manager != nil {
+		word := trigger
+		if !strings.HasPrefix(word, manager.Settings.Symbol) {
+			word = manager.Settings.Symbol + word
+		}
+		if c := macroFor(client, word); c != nil {
+			expansion := macroExpansion(client, message.Target, c.Triggers[0])
+			if len(expansion) > 0 {
+				msgutil.SendResponse(client, message.Source, message.Target,
+					fmt.Sprintf("Macro: %s expands to %s", word, expansion))
+				return
+			}
+		}
+	}
+
+	// Send response if no command was found.
+	msgutil.SendResponse(client, message.Source, message.Target,
+		"Command not found, use help without arguments to list commands")
+}
+
+// matchTrigger checks whether user input names a command trigger, with or
+// without its symbol.
+func matchTrigger(client *ircutil.Client, command *ircutil.Command,
+	trigger string, input string) bool {
+	full := cmdutil.FormatTrigger(client, command, trigger)
+	bare := strings.TrimPrefix(full, command.Settings.Symbol)
+	if !command.Settings.CaseSensitive {
+		return strings.EqualFold(input, full) || strings.EqualFold(input, bare)
+	}
+	return input == full || input == bare
+}
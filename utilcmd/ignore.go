@@ -0,0 +1,120 @@
+package utilcmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Ignore drops messages from a hostmask pattern, optionally for a limited time
+// or only in a channel. Function key: inami/utilcmd.Ignore
+func Ignore(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Set pattern, duration, and channel, allowing the duration to be omitted.
+	args := configutil.GetArgs(command, message)
+	pattern := cmdutil.NormalizeMask(args["mask"])
+	durationStr, channel := args["duration"], args["channel"]
+	if ircutil.IsChannel(durationStr) && len(channel) < 1 {
+		durationStr, channel = "", durationStr
+	}
+
+	// Parse duration if specified.
+	var duration time.Duration
+	if len(durationStr) > 0 {
+		var err error
+		duration, err = parseDuration(durationStr)
+		if err != nil || duration <= 0 {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Invalid duration, use a format like 30m, 12h, or 7d")
+			return
+		}
+	}
+
+	// Save pattern and send response with confirmation.
+	err := cmdutil.Ignore(client, channel, pattern, duration)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error saving ignore, try again later")
+		return
+	}
+	until := ""
+	if duration > 0 {
+		until = fmt.Sprintf(" for %s", durationStr)
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Ignoring %s%s%s", pattern, inChannel(channel), until))
+}
+
+// Unignore stops ignoring a hostmask pattern.
+// Function key: inami/utilcmd.Unignore
+func Unignore(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Make sure the pattern is ignored.
+	args := configutil.GetArgs(command, message)
+	pattern, channel := cmdutil.NormalizeMask(args["mask"]), args["channel"]
+	ignores := cmdutil.GetIgnores(client, channel)
+	if _, ok := ignores[strings.ToLower(pattern)]; !ok {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("%s isn't ignored%s", pattern, inChannel(channel)))
+		return
+	}
+
+	// Remove pattern and send response with confirmation.
+	err := cmdutil.Unignore(client, channel, pattern)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error removing ignore, try again later")
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("No longer ignoring %s%s", pattern, inChannel(channel)))
+}
+
+// Ignores lists ignored hostmask patterns. Function key: inami/utilcmd.Ignores
+func Ignores(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Send response if nothing is ignored.
+	channel := configutil.GetArgs(command, message)["channel"]
+	ignores := cmdutil.GetIgnores(client, channel)
+	if len(ignores) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Nothing is ignored%s", inChannel(channel)))
+		return
+	}
+
+	// Send response with each pattern and its expiry.
+	var entries []string
+	for pattern, expiry := range ignores {
+		if expiry.IsZero() {
+			entries = append(entries, pattern)
+		} else {
+			entries = append(entries, fmt.Sprintf("%s (%s left)", pattern,
+				expiry.Sub(clockutil.Now()).Round(time.Minute)))
+		}
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Ignoring%s: %s", inChannel(channel),
+			strings.Join(entries, ", ")))
+}
+
+// parseDuration parses a duration like time.ParseDuration, but also accepts a
+// number of days such as "7d".
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
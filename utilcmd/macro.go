@@ -0,0 +1,277 @@
+package utilcmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/eventutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// macrosGroup is the data group channel macros are stored in.
+const macrosGroup = "utility/macros"
+
+// macroKey is the function key that runs macros. Macros aren't added to a
+// client's commands, they are looked up in a channel's data when a message
+// starts with the symbol of the command that manages them.
+const macroKey = "inami/utilcmd.RunMacro"
+
+// macroName matches valid macro names.
+var macroName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Macro adds or removes a channel macro. A macro expands to an existing
+// command with preset arguments if its expansion starts with that command's
+// trigger, or to a reply template otherwise. Templates can use {nick},
+// {channel}, {args}, and {1} through {9}. Function key: inami/utilcmd.Macro
+func Macro(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Set action, name, and expansion.
+	args := configutil.GetArgs(command, message)
+	action, name, expansion := args["action"], args["name"], args["expansion"]
+	keys := []string{"channel", message.Target, macrosGroup,
+		macroID(command, name)}
+
+	switch action {
+	case "add", "set":
+		// Make sure the macro is valid and doesn't replace a command.
+		if len(expansion) < 1 {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("Usage: %s", cmdutil.Usage(client, command)))
+			return
+		}
+		if !macroName.MatchString(name) {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Invalid macro name, use letters, numbers, dashes, or underscores")
+			return
+		}
+		if findCommand(client, command.Settings.Symbol+name) != nil {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"A command with that name already exists")
+			return
+		}
+		if isMacro(client, message.Target, strings.Fields(expansion)[0]) {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Macros can't run other macros")
+			return
+		}
+
+		// Save macro and send response with confirmation.
+		err := configutil.SetValue(client, keys, expansion)
+		if err != nil {
+			ircutil.Log(client, err.Error())
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Error saving macro, try again later")
+			return
+		}
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Saved macro %s%s", command.Settings.Symbol, name))
+
+	case "remove", "del":
+		// Make sure the macro exists, then remove it.
+		expansion, _ := configutil.GetValue(client, keys)
+		if len(expansion) < 1 {
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Macro not found")
+			return
+		}
+		err := configutil.DeleteValue(client, keys)
+		if err != nil {
+			ircutil.Log(client, err.Error())
+			msgutil.SendResponse(client, message.Source, message.Target,
+				"Error removing macro, try again later")
+			return
+		}
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Removed macro %s%s", command.Settings.Symbol, name))
+
+	default:
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Usage: %s", cmdutil.Usage(client, command)))
+	}
+}
+
+// Macros lists the macros in a channel. Function key: inami/utilcmd.Macros
+func Macros(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Send response with macro names, or if there are none.
+	macros := macroTriggers(client, message.Target)
+	if len(macros) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"No macros here")
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Macros: %s", strings.Join(macros, ", ")))
+}
+
+// RunMacro expands a channel macro and either runs its command or sends its
+// reply. Macros not defined in the current channel are ignored.
+// Function key: inami/utilcmd.RunMacro
+func RunMacro(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Get expansion for the macro in this channel.
+	if !ircutil.IsChannel(message.Target) || len(command.Triggers) < 1 {
+		return
+	}
+	keys := []string{"channel", message.Target, macrosGroup, command.Triggers[0]}
+	expansion, err := configutil.GetValue(client, keys)
+	if err != nil || len(expansion) < 1 {
+		return
+	}
+
+	// Run the expanded command with preset arguments followed by the macro's
+	// own arguments.
+	words := strings.Fields(expansion)
+	if c := findCommand(client, words[0]); c != nil {
+		fn, ok := client.CmdMap[c.Function]
+		if !ok || !cmdutil.Available(client, c, message) {
+			return
+		}
+		expanded := *message
+		expanded.Args = append(append([]string{}, words[1:]...),
+			message.Args...)
+		fn(client, c, &expanded)
+		return
+	}
+
+	// Otherwise fill in the reply template and send it.
+	reply := strings.NewReplacer(
+		"{nick}", ircutil.GetNick(message.Source),
+		"{channel}", message.Target,
+		"{args}", strings.Join(message.Args, " "),
+	).Replace(expansion)
+	for i := 1; i <= 9; i++ {
+		arg := ""
+		if i <= len(message.Args) {
+			arg = message.Args[i-1]
+		}
+		reply = strings.Replace(reply, fmt.Sprintf("{%d}", i), arg, -1)
+	}
+	msgutil.SendResponse(client, message.Source, message.Target, reply)
+}
+
+// subscribeMacros subscribes to channel messages so a macro runs when its
+// trigger starts a message in a channel it's defined in.
+func subscribeMacros() {
+	eventutil.Subscribe("PRIVMSG", eventutil.Filter{}, func(e *eventutil.Event) {
+		words := strings.Fields(e.Text)
+		if len(e.Channel) < 1 || len(words) < 1 {
+			return
+		}
+
+		// Build the command for the macro, leaving triggers of real commands to
+		// the client.
+		client := e.Client
+		c := macroFor(client, words[0])
+		if c == nil || findCommand(client, words[0]) != nil {
+			return
+		}
+		message := &ircutil.Message{Source: e.Source, Target: e.Channel,
+			Args: words[1:]}
+		if len(macroExpansion(client, e.Channel, c.Triggers[0])) < 1 ||
+			!cmdutil.Available(client, c, message) {
+			return
+		}
+		if fn, ok := client.CmdMap[macroKey]; ok {
+			fn(client, c, message)
+		}
+	})
+}
+
+// macroFor returns the command that runs the macro a word triggers, using
+// the settings of the command that manages macros, or nil if the word doesn't
+// start with its symbol.
+func macroFor(client *ircutil.Client, word string) *ircutil.Command {
+	manager := macroManager(client)
+	if manager == nil || !strings.HasPrefix(word, manager.Settings.Symbol) {
+		return nil
+	}
+	name := strings.TrimPrefix(word, manager.Settings.Symbol)
+	if !macroName.MatchString(name) {
+		return nil
+	}
+	settings := manager.Settings
+	settings.Scope = []string{"channel"}
+	return &ircutil.Command{
+		Triggers:  []string{macroID(manager, name)},
+		Function:  macroKey,
+		Arguments: "[args...]",
+		Settings:  settings,
+	}
+}
+
+// macroManager returns the command that manages macros, or nil if a client
+// doesn't have one.
+func macroManager(client *ircutil.Client) *ircutil.Command {
+	for i := range client.Commands {
+		if client.Commands[i].Function == "inami/utilcmd.Macro" {
+			return &client.Commands[i]
+		}
+	}
+	return nil
+}
+
+// macroID returns the name a macro is stored under, which keeps its case
+// only if the command that manages macros is case sensitive.
+func macroID(manager *ircutil.Command, name string) string {
+	if manager.Settings.CaseSensitive {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+// isMacro checks whether a word triggers a macro defined in a channel.
+func isMacro(client *ircutil.Client, channel string, word string) bool {
+	c := macroFor(client, word)
+	return c != nil && len(macroExpansion(client, channel, c.Triggers[0])) > 0
+}
+
+// macroExpansion returns the expansion of a macro in a channel, or nothing if
+// it isn't defined there.
+func macroExpansion(client *ircutil.Client, channel string,
+	id string) string {
+	if !ircutil.IsChannel(channel) {
+		return ""
+	}
+	expansion, _ := configutil.GetValue(client, []string{"channel", channel,
+		macrosGroup, id})
+	return expansion
+}
+
+// macroTriggers returns the formatted triggers of every macro defined in a
+// channel, sorted by name.
+func macroTriggers(client *ircutil.Client, channel string) []string {
+	manager := macroManager(client)
+	if manager == nil || !ircutil.IsChannel(channel) {
+		return nil
+	}
+	macros, _ := configutil.GetGroup(client,
+		[]string{"channel", channel, macrosGroup})
+	var triggers []string
+	for id := range macros {
+		triggers = append(triggers, manager.Settings.Symbol+id)
+	}
+	sort.Strings(triggers)
+	return triggers
+}
+
+// findCommand returns the command whose trigger, including its symbol,
+// matches a word, or nil if there is none.
+func findCommand(client *ircutil.Client, word string) *ircutil.Command {
+	for i := range client.Commands {
+		c := &client.Commands[i]
+		for _, t := range c.Triggers {
+			full := cmdutil.FormatTrigger(client, c, t)
+			if word == full || !c.Settings.CaseSensitive &&
+				strings.EqualFold(word, full) {
+				return c
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package utilcmd
+
+import (
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/eventutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// subscribePatterns subscribes to messages so commands with a pattern run
+// when it matches.
+func subscribePatterns() {
+	eventutil.Subscribe("PRIVMSG", eventutil.Filter{}, func(e *eventutil.Event) {
+		// Build the message a triggered command would receive.
+		target := e.Channel
+		if len(target) < 1 {
+			target = e.Target
+		}
+		base := ircutil.Message{Source: e.Source, Target: target}
+
+		// Run every available command whose pattern matches, passing capture
+		// groups as arguments. Commands whose arguments don't fit are skipped
+		// silently, since the message was ordinary chat rather than a request
+		// for usage.
+		client := e.Client
+		for i := range client.Commands {
+			c := &client.Commands[i]
+			info := configutil.GetCommandInfo(c)
+			if info.Regexp == nil {
+				continue
+			}
+			groups := info.Regexp.FindStringSubmatch(e.Text)
+			if groups == nil || !cmdutil.Available(client, c, &base) {
+				continue
+			}
+			args, ok := patternArgs(info.Spec, groups[1:])
+			if !ok {
+				continue
+			}
+			fn, ok := client.CmdMap[c.Function]
+			if !ok {
+				continue
+			}
+			message := base
+			message.Args = args
+			cmdutil.Go(client, "pattern command", func() {
+				fn(client, c, &message)
+			})
+		}
+	})
+}
+
+// patternArgs turns the capture groups of a pattern match into arguments,
+// keeping each group in its position so it binds to the right argument.
+// Trailing groups that matched nothing are left out so optional arguments can
+// be missing. It returns false if the arguments don't fit the spec or a
+// required one is empty.
+func patternArgs(spec configutil.ArgSpec, groups []string) ([]string, bool) {
+	args := groups
+	for len(args) > 0 && len(args[len(args)-1]) < 1 {
+		args = args[:len(args)-1]
+	}
+	named, err := spec.Bind(args)
+	if err != nil {
+		return nil, false
+	}
+	for _, a := range spec {
+		if a.Required && len(named[a.Name]) < 1 {
+			return nil, false
+		}
+	}
+	return args, true
+}
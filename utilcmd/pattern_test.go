@@ -0,0 +1,40 @@
+package utilcmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+)
+
+func TestPatternArgs(t *testing.T) {
+	tests := []struct {
+		spec   string
+		groups []string
+		want   []string
+		ok     bool
+	}{
+		{"<nick> <change>", []string{"alice", "++"}, []string{"alice", "++"},
+			true},
+		{"<find> [replace]", []string{"foo", ""}, []string{"foo"}, true},
+		{"<find> [replace]", []string{"foo", "bar"}, []string{"foo", "bar"},
+			true},
+		{"[first] [second]", []string{"", "b"}, []string{"", "b"}, true},
+		{"<first> [second]", []string{"", "b"}, nil, false},
+		{"<nick>", []string{""}, nil, false},
+		{"<nick>", []string{"a", "b"}, nil, false},
+		{"", []string{""}, nil, true},
+	}
+	for _, tt := range tests {
+		spec, err := configutil.ParseArgSpec(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseArgSpec(%q): %s", tt.spec, err)
+		}
+		got, ok := patternArgs(spec, tt.groups)
+		if ok != tt.ok || ok && len(got)+len(tt.want) > 0 &&
+			!reflect.DeepEqual(got, tt.want) {
+			t.Errorf("patternArgs(%q, %q) = %q, %t, want %q, %t", tt.spec,
+				tt.groups, got, ok, tt.want, tt.ok)
+		}
+	}
+}
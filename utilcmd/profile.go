@@ -2,9 +2,9 @@ package utilcmd
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
 	"github.com/jasonpuglisi/ircutil"
 )
 
@@ -12,13 +12,14 @@ import (
 func SetProfileItem(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
 	// Set name and value, and update scope/owner to match command scope.
-	name, value := message.Args[0], strings.Join(message.Args[1:], " ")
+	args := configutil.GetArgs(command, message)
+	name, value := args["name"], args["value"]
 	keys := []string{"", "", "utility/profile", name}
 	configutil.UpdateScope(keys, message.Source, message.Source)
 
 	// Set profile item in persistent data and send response with confirmation.
 	configutil.SetValue(client, keys, value)
-	ircutil.SendResponse(client, message.Source, message.Target,
+	msgutil.SendResponse(client, message.Source, message.Target,
 		fmt.Sprintf("Your %s is now %s", name, value))
 }
 
@@ -26,7 +27,7 @@ func SetProfileItem(client *ircutil.Client, command *ircutil.Command,
 func GetProfileItem(client *ircutil.Client, command *ircutil.Command,
 	message *ircutil.Message) {
 	// Set name and update scope/owner to match command scope.
-	name := message.Args[0]
+	name := configutil.GetArgs(command, message)["name"]
 	keys := []string{"", "", "utility/profile", name}
 	configutil.UpdateScope(keys, message.Source, message.Source)
 
@@ -34,17 +35,17 @@ func GetProfileItem(client *ircutil.Client, command *ircutil.Command,
 	value, err := configutil.GetValue(client, keys)
 	if err != nil {
 		ircutil.Log(client, err.Error())
-		ircutil.SendResponse(client, message.Source, message.Target,
+		msgutil.SendResponse(client, message.Source, message.Target,
 			"Error getting profile item, try again later")
 		return
 	}
 	if len(value) < 1 {
-		ircutil.SendResponse(client, message.Source, message.Target,
+		msgutil.SendResponse(client, message.Source, message.Target,
 			"Profile item not found, make sure it exists")
 		return
 	}
 
 	// Send response with profile item.
-	ircutil.SendResponse(client, message.Source, message.Target,
+	msgutil.SendResponse(client, message.Source, message.Target,
 		fmt.Sprintf("Your %s is %s", name, value))
 }
@@ -0,0 +1,142 @@
+package utilcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jasonpuglisi/inami-irc-bot/cmdutil"
+	"github.com/jasonpuglisi/inami-irc-bot/configutil"
+	"github.com/jasonpuglisi/inami-irc-bot/msgutil"
+	"github.com/jasonpuglisi/ircutil"
+)
+
+// Grant gives a role to a hostmask pattern or services account, globally or in
+// a channel. Function key: inami/utilcmd.Grant
+func Grant(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Check that the role can be granted by the source.
+	args := configutil.GetArgs(command, message)
+	role, grant, channel := args["role"], args["grant"], args["channel"]
+	if !checkGrant(client, message, role, channel) {
+		return
+	}
+
+	// Refuse grants that would match everyone.
+	if strings.Trim(grant, "*?!@") == "" || grant == "$a:" {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Grant must match someone, not everyone")
+		return
+	}
+
+	// Save grant and send response with confirmation.
+	err := cmdutil.GrantRole(client, channel, role, grant)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error saving role, try again later")
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Granted %s to %s%s", role, grant, inChannel(channel)))
+}
+
+// Revoke removes a role from a hostmask pattern or services account, globally
+// or in a channel. Function key: inami/utilcmd.Revoke
+func Revoke(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Check that the role can be revoked by the source.
+	args := configutil.GetArgs(command, message)
+	role, grant, channel := args["role"], args["grant"], args["channel"]
+	if !checkGrant(client, message, role, channel) {
+		return
+	}
+
+	// Remove grant and send response with confirmation.
+	found, err := cmdutil.RevokeRole(client, channel, role, grant)
+	if err != nil {
+		ircutil.Log(client, err.Error())
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Error saving role, try again later")
+		return
+	}
+	if !found {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("%s doesn't have %s%s", grant, role, inChannel(channel)))
+		return
+	}
+	msgutil.SendResponse(client, message.Source, message.Target,
+		fmt.Sprintf("Revoked %s from %s%s", role, grant, inChannel(channel)))
+}
+
+// Roles lists saved role grants, globally or in a channel.
+// Function key: inami/utilcmd.Roles
+func Roles(client *ircutil.Client, command *ircutil.Command,
+	message *ircutil.Message) {
+	// Send response with grants for each role that has any.
+	channel := configutil.GetArgs(command, message)["channel"]
+	found := false
+	for _, role := range cmdutil.RoleNames {
+		grants := cmdutil.GetGrants(client, channel, role)
+		if len(grants) > 0 {
+			found = true
+			msgutil.SendResponse(client, message.Source, message.Target,
+				fmt.Sprintf("%s: %s", role, strings.Join(grants, ", ")))
+		}
+	}
+
+	// Send response if there are no grants.
+	if !found {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("No roles granted%s", inChannel(channel)))
+	}
+}
+
+// checkGrant makes sure a role is valid and that the message source holds a
+// higher one where it would be granted, and sends a response if not.
+func checkGrant(client *ircutil.Client, message *ircutil.Message,
+	role string, channel string) bool {
+	// Make sure the role and channel are valid.
+	if cmdutil.RoleLevel(role) == 0 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("Invalid role, must be one of %s",
+				strings.Join(cmdutil.RoleNames, ", ")))
+		return false
+	}
+	if len(channel) > 0 && !ircutil.IsChannel(channel) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Invalid channel")
+		return false
+	}
+	if role == "moderator" && len(channel) < 1 {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Moderators must be granted in a channel")
+		return false
+	}
+
+	// Only allow roles to be managed by someone who holds a higher one, so
+	// holding a role, such as from channel status, isn't enough to hand it
+	// out. Owners can only be granted in the config file.
+	level := cmdutil.RoleLevel(role)
+	if level == len(cmdutil.RoleNames) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			"Owners can only be granted in the config file")
+		return false
+	}
+	higher := cmdutil.RoleNames[level]
+	scoped := &ircutil.Message{Source: message.Source, Target: channel}
+	if !cmdutil.HasRole(client, scoped, higher) {
+		msgutil.SendResponse(client, message.Source, message.Target,
+			fmt.Sprintf("You need to be %s%s to manage %s", higher,
+				inChannel(channel), role))
+		return false
+	}
+	return true
+}
+
+// inChannel returns a suffix naming a channel, or nothing if it's empty.
+func inChannel(channel string) string {
+	if len(channel) < 1 {
+		return ""
+	}
+	return " in " + channel
+}
@@ -0,0 +1,218 @@
+package webutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+)
+
+// MaxWait is the longest a client will wait before retrying a request. If a
+// server asks for a longer wait, the request fails instead.
+const MaxWait = 30 * time.Second
+
+// Client sends JSON requests to a web API, retrying when the server is busy or
+// having problems.
+type Client struct {
+	// Base URL that request paths are appended to. Paths that are already
+	// absolute URLs are used as they are.
+	BaseURL string
+	// User-Agent header sent with every request.
+	UserAgent string
+	// Number of times a request is retried after a 429 or 5xx response.
+	Retries int
+	// Wait before the first retry, which doubles with each retry unless the
+	// server sends a Retry-After header.
+	Backoff time.Duration
+	// HTTP client used to send requests, which sets the timeout.
+	HTTP *http.Client
+}
+
+// NewClient creates a client for an API at a base URL, with a timeout for each
+// request attempt.
+func NewClient(baseURL, userAgent string, timeout time.Duration,
+	retries int) *Client {
+	return &Client{
+		BaseURL:   strings.TrimRight(baseURL, "/"),
+		UserAgent: userAgent,
+		Retries:   retries,
+		Backoff:   time.Second,
+		HTTP:      &http.Client{Timeout: timeout},
+	}
+}
+
+// StatusError is returned when a server responds with an unsuccessful status
+// code.
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("fetching %s: status %d %s", e.URL, e.Code,
+		http.StatusText(e.Code))
+}
+
+// TimeoutError is returned when a server doesn't respond in time.
+type TimeoutError struct {
+	URL string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("fetching %s: timed out: %v", e.URL, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// DecodeError is returned when a response body isn't the JSON that was
+// expected.
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("fetching %s: invalid response: %v", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// IsStatus checks whether an error is a status error with a status code.
+func IsStatus(err error, code int) bool {
+	var s *StatusError
+	return errors.As(err, &s) && s.Code == code
+}
+
+// Get sends a GET request to a path and parses the JSON response into v.
+func (c *Client) Get(path string, v interface{}) error {
+	return c.Do(http.MethodGet, path, nil, v)
+}
+
+// Post sends a POST request to a path with a JSON body, and parses the JSON
+// response into v.
+func (c *Client) Post(path string, body interface{}, v interface{}) error {
+	return c.Do(http.MethodPost, path, body, v)
+}
+
+// Do sends a request to a path with an optional JSON body, and parses the
+// JSON response into v. Requests that get a 429 or 5xx response are retried.
+func (c *Client) Do(method, path string, body interface{},
+	v interface{}) error {
+	// Build full URL and encode body.
+	link := path
+	if !strings.Contains(path, "://") {
+		link = c.BaseURL + path
+	}
+	var raw []byte
+	if body != nil {
+		var err error
+		raw, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Send request until it succeeds or can't be retried.
+	for attempt := 0; ; attempt++ {
+		data, wait, err := c.send(method, link, raw)
+		if err == nil {
+			if err = json.Unmarshal(data, v); err != nil {
+				return &DecodeError{URL: link, Err: err}
+			}
+			return nil
+		}
+
+		// Give up if the error isn't worth retrying, or after the last retry.
+		if wait < 0 || attempt >= c.Retries {
+			return err
+		}
+		if wait == 0 {
+			wait = c.Backoff << uint(attempt)
+		}
+		if wait > MaxWait {
+			return err
+		}
+		<-clockutil.After(wait)
+	}
+}
+
+// send makes one attempt at a request, and returns the response body. If the
+// attempt failed, it also returns how long the server asked to wait before
+// retrying, which is zero if it didn't say, or negative if the request
+// shouldn't be retried.
+func (c *Client) send(method, link string, raw []byte) ([]byte,
+	time.Duration, error) {
+	// Create request with headers.
+	var reader io.Reader
+	if raw != nil {
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, link, reader)
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.UserAgent)
+	if raw != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Send request, treating timeouts separately from other failures.
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		var n net.Error
+		if errors.As(err, &n) && n.Timeout() {
+			return nil, -1, &TimeoutError{URL: link, Err: err}
+		}
+		return nil, -1, err
+	}
+	defer resp.Body.Close()
+
+	// Read data body, timing out if it's sent too slowly.
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		var n net.Error
+		if errors.As(err, &n) && n.Timeout() {
+			return nil, -1, &TimeoutError{URL: link, Err: err}
+		}
+		return nil, -1, err
+	}
+
+	// Check status code, and allow retries for busy or broken servers.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = &StatusError{URL: link, Code: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests ||
+			resp.StatusCode >= 500 {
+			return data, retryAfter(resp.Header.Get("Retry-After")), err
+		}
+		return data, -1, err
+	}
+	return data, 0, nil
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds
+// or a date. It returns zero if the header is missing or invalid.
+func retryAfter(header string) time.Duration {
+	if len(header) < 1 {
+		return 0
+	}
+	if s, err := strconv.Atoi(header); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if wait := t.Sub(clockutil.Now()); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package webutil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jasonpuglisi/inami-irc-bot/clockutil"
+)
+
+// start is the time the test clock is set to.
+var start = time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// recordClock is a clock whose timers fire right away, recording how long
+// each one was for.
+type recordClock struct {
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (c *recordClock) Now() time.Time {
+	return start
+}
+
+func (c *recordClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.waits = append(c.waits, d)
+	c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	ch <- start.Add(d)
+	return ch
+}
+
+// reply stores a response sent by the test server.
+type reply struct {
+	code       int
+	retryAfter string
+	body       string
+}
+
+func TestClientRetry(t *testing.T) {
+	ok := reply{200, "", `{"ok":true}`}
+	busy := reply{503, "", ""}
+	tests := []struct {
+		name    string
+		replies []reply
+		waits   []time.Duration
+		status  int
+		decode  bool
+	}{
+		{"success", []reply{ok}, nil, 0, false},
+		{"backoff", []reply{busy, {500, "", ""}, ok},
+			[]time.Duration{time.Second, 2 * time.Second}, 0, false},
+		{"retries exhausted", []reply{busy, busy, busy},
+			[]time.Duration{time.Second, 2 * time.Second}, 503, false},
+		{"retry after seconds", []reply{{429, "5", ""}, ok},
+			[]time.Duration{5 * time.Second}, 0, false},
+		{"retry after date", []reply{{429,
+			start.Add(10 * time.Second).Format(http.TimeFormat), ""}, ok},
+			[]time.Duration{10 * time.Second}, 0, false},
+		{"retry after invalid", []reply{{429, "soon", ""}, ok},
+			[]time.Duration{time.Second}, 0, false},
+		{"retry after too long", []reply{{429, "60", ""}, ok}, nil, 429,
+			false},
+		{"not retried", []reply{{404, "", ""}, ok}, nil, 404, false},
+		{"invalid body", []reply{{200, "", "<html>"}}, nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &recordClock{}
+			defer clockutil.Set(clock)()
+
+			// Serve each reply in turn.
+			var mu sync.Mutex
+			replies := tt.replies
+			server := httptest.NewServer(http.HandlerFunc(func(
+				w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if len(replies) < 1 {
+					t.Errorf("unexpected request for %s", r.URL)
+					w.WriteHeader(http.StatusTeapot)
+					return
+				}
+				rep := replies[0]
+				replies = replies[1:]
+				if len(rep.retryAfter) > 0 {
+					w.Header().Set("Retry-After", rep.retryAfter)
+				}
+				w.WriteHeader(rep.code)
+				w.Write([]byte(rep.body))
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, "test", time.Second, 2)
+			v := struct{ OK bool }{}
+			err := client.Get("/", &v)
+			var decode *DecodeError
+			switch {
+			case tt.status > 0 && !IsStatus(err, tt.status):
+				t.Errorf("got error %v, want status %d", err, tt.status)
+			case tt.decode && !errors.As(err, &decode):
+				t.Errorf("got error %v, want decode error", err)
+			case tt.status == 0 && !tt.decode && (err != nil || !v.OK):
+				t.Errorf("got %+v, %v, want success", v, err)
+			}
+			if !reflect.DeepEqual(clock.waits, tt.waits) {
+				t.Errorf("waited %v, want %v", clock.waits, tt.waits)
+			}
+		})
+	}
+}